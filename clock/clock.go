@@ -0,0 +1,91 @@
+// Package clock provides the source of time of dcron and its scheduler,
+// so the tests can replace it with a fake clock, see package clocktest.
+package clock
+
+import "time"
+
+// Clock is the source of the current time, the timers and the tickers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a Timer sending the time on its channel after d.
+	NewTimer(d time.Duration) Timer
+	// NewTicker creates a Ticker sending the time on its channel every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Monotonic is implemented by the clocks keeping a monotonic time apart from
+// the wall time, which setting the clock does not move, so a jump of the
+// wall clock can be told apart from the time passing.
+type Monotonic interface {
+	// Monotonic returns the monotonic time passed since the clock was created.
+	Monotonic() time.Duration
+}
+
+// Timer is a time.Timer created by a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a time.Ticker created by a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real returns the Clock of the system time.
+func Real() Clock {
+	return realClock{}
+}
+
+// realStart is the origin of the monotonic time of the real clock.
+var realStart = time.Now()
+
+type realClock struct{}
+
+func (realClock) Monotonic() time.Duration {
+	return time.Since(realStart)
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Sleep pauses the current goroutine for d on the clock.
+func Sleep(c Clock, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-c.NewTimer(d).C()
+}
+
+// Since returns the time elapsed since t on the clock.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
@@ -0,0 +1,197 @@
+// Package clocktest provides a fake clock for testing dcron and its
+// scheduler without sleeping, e.g. across DST transitions.
+package clocktest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libi/dcron/clock"
+)
+
+// Fake is a clock.Clock which only moves when it is advanced, firing the
+// timers and tickers due on the way, in order.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	elapsed time.Duration // the monotonic time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending timer or ticker.
+type fakeWaiter struct {
+	clock  *Fake
+	at     time.Time
+	period time.Duration // zero for the timers
+	c      chan time.Time
+}
+
+var (
+	_ clock.Clock     = (*Fake)(nil)
+	_ clock.Monotonic = (*Fake)(nil)
+)
+
+// NewFake creates a Fake clock at now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the current time of the clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Monotonic returns how far the clock was advanced, the time set by Set is
+// not counted.
+func (f *Fake) Monotonic() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.elapsed
+}
+
+// NewTimer creates a timer firing when the clock is advanced by d.
+func (f *Fake) NewTimer(d time.Duration) clock.Timer {
+	w := &fakeWaiter{clock: f, c: make(chan time.Time, 1)}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.startTimer(w, d)
+	return (*fakeTimer)(w)
+}
+
+// NewTicker creates a ticker firing every time the clock is advanced by d.
+func (f *Fake) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{clock: f, period: d, c: make(chan time.Time, 1)}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(w, f.now.Add(d))
+	return (*fakeTicker)(w)
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers due.
+// Like time.Ticker, a ticker drops the ticks its reader is too slow for.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := f.now.Add(d)
+	for len(f.waiters) > 0 && !f.waiters[0].at.After(end) {
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		f.now = w.at
+		select {
+		case w.c <- w.at:
+		default:
+		}
+		if w.period > 0 {
+			f.schedule(w, w.at.Add(w.period))
+		}
+	}
+	f.now = end
+	f.elapsed += d
+	f.cond.Broadcast()
+}
+
+// Set jumps the clock to t without the time passing, like the system clock
+// being set: the pending timers and tickers fire after the same durations
+// as before, because the timers of the system are monotonic.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	jump := t.Sub(f.now)
+	for _, w := range f.waiters {
+		w.at = w.at.Add(jump)
+	}
+	f.now = t
+}
+
+// BlockUntil blocks until at least n timers and tickers are pending,
+// e.g. until the scheduler is waiting for the next activation.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// Waiters returns the number of the pending timers and tickers.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// startTimer schedules the timer w firing after d, or fires it now if d is
+// not positive, like time.NewTimer. It must be called with mu held.
+func (f *Fake) startTimer(w *fakeWaiter, d time.Duration) {
+	if d > 0 {
+		f.schedule(w, f.now.Add(d))
+		return
+	}
+	select {
+	case w.c <- f.now:
+	default:
+	}
+}
+
+// schedule adds w firing at at, it must be called with mu held.
+func (f *Fake) schedule(w *fakeWaiter, at time.Time) {
+	w.at = at
+	i := sort.Search(len(f.waiters), func(i int) bool { return f.waiters[i].at.After(at) })
+	f.waiters = append(f.waiters, nil)
+	copy(f.waiters[i+1:], f.waiters[i:])
+	f.waiters[i] = w
+	f.cond.Broadcast()
+}
+
+// remove removes w, it returns false if w is not pending.
+// It must be called with mu held.
+func (f *Fake) remove(w *fakeWaiter) bool {
+	for i, pending := range f.waiters {
+		if pending == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer fakeWaiter
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove((*fakeWaiter)(t))
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.clock.remove((*fakeWaiter)(t))
+	t.clock.startTimer((*fakeWaiter)(t), d)
+	return active
+}
+
+type fakeTicker fakeWaiter
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.remove((*fakeWaiter)(t))
+}
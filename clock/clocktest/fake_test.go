@@ -0,0 +1,115 @@
+package clocktest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libi/dcron/clock"
+	"github.com/libi/dcron/clock/clocktest"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2012, time.July, 9, 14, 45, 0, 0, time.UTC)
+
+func received(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeTimer(t *testing.T) {
+	fake := clocktest.NewFake(start)
+	timer1 := fake.NewTimer(time.Second)
+	timer2 := fake.NewTimer(2 * time.Second)
+	require.Equal(t, 2, fake.Waiters())
+
+	fake.Advance(999 * time.Millisecond)
+	_, ok := received(timer1.C())
+	require.False(t, ok)
+
+	fake.Advance(time.Millisecond)
+	fired, ok := received(timer1.C())
+	require.True(t, ok)
+	require.Equal(t, start.Add(time.Second), fired)
+	require.Equal(t, start.Add(time.Second), fake.Now())
+	require.Equal(t, 1, fake.Waiters())
+
+	require.True(t, timer2.Stop())
+	require.False(t, timer2.Stop())
+	fake.Advance(time.Hour)
+	_, ok = received(timer2.C())
+	require.False(t, ok)
+
+	require.False(t, timer2.Reset(time.Minute))
+	fake.Advance(time.Minute)
+	fired, ok = received(timer2.C())
+	require.True(t, ok)
+	require.Equal(t, start.Add(time.Second+time.Hour+time.Minute), fired)
+
+	// a timer of no duration fires immediately, like time.NewTimer.
+	_, ok = received(fake.NewTimer(0).C())
+	require.True(t, ok)
+}
+
+func TestFakeTicker(t *testing.T) {
+	fake := clocktest.NewFake(start)
+	ticker := fake.NewTicker(time.Second)
+
+	fake.Advance(time.Second)
+	fired, ok := received(ticker.C())
+	require.True(t, ok)
+	require.Equal(t, start.Add(time.Second), fired)
+
+	// the ticks the reader is too slow for are dropped.
+	fake.Advance(3 * time.Second)
+	fired, ok = received(ticker.C())
+	require.True(t, ok)
+	require.Equal(t, start.Add(2*time.Second), fired)
+	_, ok = received(ticker.C())
+	require.False(t, ok)
+
+	ticker.Stop()
+	fake.Advance(time.Hour)
+	_, ok = received(ticker.C())
+	require.False(t, ok)
+	require.Equal(t, 0, fake.Waiters())
+}
+
+func TestFakeSet(t *testing.T) {
+	fake := clocktest.NewFake(start)
+	timer := fake.NewTimer(time.Hour)
+
+	// the timers fire after the same durations whichever way the clock jumps.
+	fake.Set(start.Add(-time.Hour))
+	require.Equal(t, start.Add(-time.Hour), fake.Now())
+	fake.Advance(59 * time.Minute)
+	_, ok := received(timer.C())
+	require.False(t, ok)
+	fake.Set(start.Add(24 * time.Hour))
+	_, ok = received(timer.C())
+	require.False(t, ok)
+	fake.Advance(time.Minute)
+	fired, ok := received(timer.C())
+	require.True(t, ok)
+	require.Equal(t, start.Add(24*time.Hour+time.Minute), fired)
+	require.Equal(t, time.Hour, fake.Monotonic())
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	fake := clocktest.NewFake(start)
+	done := make(chan struct{})
+	go func() {
+		clock.Sleep(fake, time.Minute)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sleep is not woken up by Advance")
+	}
+}
@@ -0,0 +1,135 @@
+// Command dcron-ring prints how the jobs are distributed to the nodes on
+// the hash ring of dcron, to help tuning WithHashReplicas and WithHashFunc.
+//
+// Usage:
+//
+//	dcron-ring -nodes node1,node2,node3 -jobs-file jobs.txt -replicas 50 -hash crc32
+//	dcron-ring -nodes-file nodes.txt -jobs job1,job2 -add node4 -remove node1
+//
+// The files contain one name per line, and the empty lines are skipped.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/libi/dcron/consistenthash"
+)
+
+var hashes = map[string]consistenthash.Hash{
+	"crc32":    consistenthash.CRC32,
+	"fnv1a":    consistenthash.FNV1a,
+	"xxhash32": consistenthash.XXHash32,
+	"murmur3":  consistenthash.Murmur3,
+}
+
+func main() {
+	var (
+		nodesFlag = flag.String("nodes", "", "comma separated node IDs")
+		nodesFile = flag.String("nodes-file", "", "file of node IDs, one per line")
+		jobsFlag  = flag.String("jobs", "", "comma separated job names")
+		jobsFile  = flag.String("jobs-file", "", "file of job names, one per line")
+		replicas  = flag.Int("replicas", 50, "hash replicas of each node")
+		hashName  = flag.String("hash", "crc32", "hash function: crc32, fnv1a, xxhash32 or murmur3")
+		addNode   = flag.String("add", "", "report the jobs moved when this node is added")
+		rmNode    = flag.String("remove", "", "report the jobs moved when this node is removed")
+	)
+	flag.Parse()
+
+	hashFn, ok := hashes[*hashName]
+	if !ok {
+		exitf("unknown hash function %q", *hashName)
+	}
+	nodes, err := readNames(*nodesFlag, *nodesFile)
+	if err != nil {
+		exitf("read nodes error: %v", err)
+	}
+	jobs, err := readNames(*jobsFlag, *jobsFile)
+	if err != nil {
+		exitf("read jobs error: %v", err)
+	}
+	if len(nodes) == 0 {
+		exitf("no nodes, set -nodes or -nodes-file")
+	}
+
+	report := consistenthash.Analyze(nodes, jobs, *replicas, hashFn)
+	fmt.Printf("nodes=%d jobs=%d replicas=%d hash=%s\n\n", len(report.Nodes), report.Keys, report.Replicas, *hashName)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "NODE\tJOBS\tJOB SHARE\tRING SHARE\t")
+	for _, share := range report.Nodes {
+		fmt.Fprintf(w, "%s\t%d\t%.2f%%\t%.2f%%\t\n", share.Node, share.Keys, share.KeyShare*100, share.RingShare*100)
+	}
+	w.Flush()
+	if len(report.Nodes) > 0 && report.Keys > 0 {
+		mean := float64(report.Keys) / float64(len(report.Nodes))
+		fmt.Printf("\nstddev=%.2f jobs (%.1f%% of mean)\n", report.StdDev, report.StdDev/mean*100)
+	}
+
+	ring := consistenthash.New(*replicas, hashFn)
+	ring.Add(nodes...)
+	if *addNode != "" {
+		added := ring.Clone()
+		added.Add(*addNode)
+		printMoves("add "+*addNode, consistenthash.Diff(ring, added, jobs), len(jobs))
+	}
+	if *rmNode != "" {
+		removed := ring.Clone()
+		removed.Remove(*rmNode)
+		printMoves("remove "+*rmNode, consistenthash.Diff(ring, removed, jobs), len(jobs))
+	}
+}
+
+func printMoves(change string, moves []consistenthash.Move, total int) {
+	share := 0.0
+	if total > 0 {
+		share = float64(len(moves)) / float64(total) * 100
+	}
+	fmt.Printf("\n%s: %d of %d jobs move (%.2f%%)\n", change, len(moves), total, share)
+	counts := make(map[string]int)
+	for _, move := range moves {
+		counts[move.From+" -> "+move.To]++
+	}
+	routes := make([]string, 0, len(counts))
+	for route := range counts {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		fmt.Printf("  %s: %d\n", route, counts[route])
+	}
+}
+
+// readNames returns the names in the comma separated list and in the file.
+func readNames(list, file string) ([]string, error) {
+	names := make([]string, 0)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if file == "" {
+		return names, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+func exitf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "dcron-ring: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -20,6 +20,7 @@ limitations under the License.
 
 import (
 	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
 )
@@ -31,6 +32,17 @@ type Map struct {
 	replicas int
 	keys     []int // Sorted
 	hashMap  map[int]string
+	members  map[string]struct{}
+	// collided keeps the members losing a hash collision, so the
+	// hash can be handed over to them when its owner is removed.
+	collided map[int][]string
+}
+
+// Move is a key whose node changed between two maps.
+type Move struct {
+	Key  string
+	From string
+	To   string
 }
 
 func New(replicas int, fn Hash) *Map {
@@ -38,6 +50,8 @@ func New(replicas int, fn Hash) *Map {
 		replicas: replicas,
 		hash:     fn,
 		hashMap:  make(map[int]string),
+		members:  make(map[string]struct{}),
+		collided: make(map[int][]string),
 	}
 	if m.hash == nil {
 		m.hash = crc32.ChecksumIEEE
@@ -51,18 +65,115 @@ func (m *Map) IsEmpty() bool {
 }
 
 // Adds some keys to the hash.
+// When two keys collide on a hash, the smaller key wins the hash,
+// so the map is the same whatever order the keys are added in.
 func (m *Map) Add(keys ...string) {
+	added := make([]int, 0, len(keys)*m.replicas)
 	for _, key := range keys {
+		if _, ok := m.members[key]; ok {
+			continue
+		}
+		m.members[key] = struct{}{}
 		for i := 0; i < m.replicas; i++ {
-			// use replicas id + _ + key to avoid the key has pre-number.
-			hash := int(m.hash([]byte(strconv.Itoa(i) + "_" + key)))
-			if m.hashMap[hash] == "" {
-				m.keys = append(m.keys, hash)
+			hash := m.replicaHash(i, key)
+			owner, ok := m.hashMap[hash]
+			switch {
+			case !ok:
 				m.hashMap[hash] = key
+				added = append(added, hash)
+			case owner == key:
+			case key < owner:
+				m.hashMap[hash] = key
+				m.collided[hash] = append(m.collided[hash], owner)
+			default:
+				m.collided[hash] = append(m.collided[hash], key)
 			}
 		}
 	}
-	sort.Ints(m.keys)
+	sort.Ints(added)
+	m.keys = mergeSorted(m.keys, added)
+}
+
+// Removes some keys from the hash.
+func (m *Map) Remove(keys ...string) {
+	removed := make(map[int]struct{})
+	for _, key := range keys {
+		if _, ok := m.members[key]; !ok {
+			continue
+		}
+		delete(m.members, key)
+		for i := 0; i < m.replicas; i++ {
+			hash := m.replicaHash(i, key)
+			if m.hashMap[hash] != key {
+				m.collided[hash] = removeString(m.collided[hash], key)
+				if len(m.collided[hash]) == 0 {
+					delete(m.collided, hash)
+				}
+				continue
+			}
+			if losers := m.collided[hash]; len(losers) > 0 {
+				// hand over the hash to the smallest loser.
+				min := 0
+				for j := range losers {
+					if losers[j] < losers[min] {
+						min = j
+					}
+				}
+				m.hashMap[hash] = losers[min]
+				m.collided[hash] = removeString(losers, losers[min])
+				if len(m.collided[hash]) == 0 {
+					delete(m.collided, hash)
+				}
+				continue
+			}
+			delete(m.hashMap, hash)
+			removed[hash] = struct{}{}
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	keys2 := m.keys[:0]
+	for _, hash := range m.keys {
+		if _, ok := removed[hash]; !ok {
+			keys2 = append(keys2, hash)
+		}
+	}
+	m.keys = keys2
+}
+
+// Members returns the sorted keys added to the hash.
+func (m *Map) Members() []string {
+	members := make([]string, 0, len(m.members))
+	for member := range m.members {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// Clone returns a copy of the map, which can be changed
+// without affecting the original one.
+func (m *Map) Clone() *Map {
+	c := &Map{
+		hash:     m.hash,
+		replicas: m.replicas,
+		keys:     make([]int, len(m.keys)),
+		hashMap:  make(map[int]string, len(m.hashMap)),
+		members:  make(map[string]struct{}, len(m.members)),
+		collided: make(map[int][]string, len(m.collided)),
+	}
+	copy(c.keys, m.keys)
+	for hash, key := range m.hashMap {
+		c.hashMap[hash] = key
+	}
+	for member := range m.members {
+		c.members[member] = struct{}{}
+	}
+	for hash, losers := range m.collided {
+		c.collided[hash] = append([]string(nil), losers...)
+	}
+	return c
 }
 
 // Gets the closest item in the hash to the provided key.
@@ -83,3 +194,140 @@ func (m *Map) Get(key string) string {
 
 	return m.hashMap[m.keys[idx]]
 }
+
+// GetN gets the n distinct items walking clockwise from the provided key,
+// the first one is the item returned by Get. Fewer items are returned
+// if there are less than n items in the hash.
+func (m *Map) GetN(key string, n int) []string {
+	if m.IsEmpty() || n <= 0 {
+		return nil
+	}
+	if n > len(m.members) {
+		n = len(m.members)
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+
+	items := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	for i := 0; len(items) < n && i < len(m.keys); i++ {
+		item := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if _, ok := seen[item]; !ok {
+			seen[item] = struct{}{}
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Diff reports the keys whose items are different in old and new.
+func Diff(old, new *Map, keys []string) []Move {
+	moves := make([]Move, 0)
+	for _, key := range keys {
+		from, to := old.Get(key), new.Get(key)
+		if from != to {
+			moves = append(moves, Move{Key: key, From: from, To: to})
+		}
+	}
+	return moves
+}
+
+func (m *Map) replicaHash(i int, key string) int {
+	// use replicas id + _ + key to avoid the key has pre-number.
+	return int(m.hash([]byte(strconv.Itoa(i) + "_" + key)))
+}
+
+// mergeSorted merges the sorted slices a and b into a sorted slice.
+func mergeSorted(a, b []int) []int {
+	if len(b) == 0 {
+		return a
+	}
+	merged := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] <= b[j] {
+			merged = append(merged, a[i])
+			i++
+		} else {
+			merged = append(merged, b[j])
+			j++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	return append(merged, b[j:]...)
+}
+
+func removeString(ss []string, s string) []string {
+	for i := range ss {
+		if ss[i] == s {
+			return append(ss[:i:i], ss[i+1:]...)
+		}
+	}
+	return ss
+}
+
+// NodeShare is the share of a node in a Report.
+type NodeShare struct {
+	Node string
+	// Keys is the number of keys assigned to the node.
+	Keys int
+	// KeyShare is the fraction of the keys assigned to the node.
+	KeyShare float64
+	// RingShare is the fraction of the hash space owned by the node.
+	RingShare float64
+}
+
+// Report is the distribution of keys on a hash ring, see Analyze.
+type Report struct {
+	Replicas int
+	Keys     int
+	// Nodes is sorted by node.
+	Nodes []NodeShare
+	// StdDev is the standard deviation of the keys assigned to each node.
+	StdDev float64
+}
+
+// Analyze builds a hash ring of the nodes, and reports how the keys
+// and the hash space are distributed to the nodes.
+func Analyze(nodes, keys []string, replicas int, fn Hash) Report {
+	m := New(replicas, fn)
+	m.Add(nodes...)
+	report := Report{Replicas: replicas, Keys: len(keys)}
+	members := m.Members()
+	if len(members) == 0 {
+		return report
+	}
+
+	counts := make(map[string]int, len(members))
+	for _, key := range keys {
+		counts[m.Get(key)]++
+	}
+	// the hash owns the arc from the previous hash to itself.
+	arcs := make(map[string]uint64, len(members))
+	for i, hash := range m.keys {
+		prev := m.keys[len(m.keys)-1] - math.MaxUint32 - 1
+		if i > 0 {
+			prev = m.keys[i-1]
+		}
+		arcs[m.hashMap[hash]] += uint64(hash - prev)
+	}
+
+	mean := float64(len(keys)) / float64(len(members))
+	var sum float64
+	for _, member := range members {
+		share := NodeShare{
+			Node:      member,
+			Keys:      counts[member],
+			RingShare: float64(arcs[member]) / (math.MaxUint32 + 1),
+		}
+		if len(keys) > 0 {
+			share.KeyShare = float64(share.Keys) / float64(len(keys))
+		}
+		report.Nodes = append(report.Nodes, share)
+		d := float64(share.Keys) - mean
+		sum += d * d
+	}
+	report.StdDev = math.Sqrt(sum / float64(len(members)))
+	return report
+}
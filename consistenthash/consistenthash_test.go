@@ -0,0 +1,240 @@
+package consistenthash
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func nodeNames(n int) []string {
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("node-%04d", i)
+	}
+	return nodes
+}
+
+func jobNames(n int) []string {
+	jobs := make([]string, n)
+	for i := range jobs {
+		jobs[i] = "job-" + strconv.Itoa(i)
+	}
+	return jobs
+}
+
+func build(replicas int, fn Hash, nodes ...string) *Map {
+	m := New(replicas, fn)
+	m.Add(nodes...)
+	return m
+}
+
+// assertSameOwners fails if a and b map any of the keys differently.
+func assertSameOwners(t *testing.T, a, b *Map, keys []string) {
+	t.Helper()
+	if moves := Diff(a, b, keys); len(moves) != 0 {
+		t.Fatalf("expected same owners, got %d moves, first %+v", len(moves), moves[0])
+	}
+	if len(a.keys) != len(b.keys) {
+		t.Fatalf("expected %d hashes, got %d", len(a.keys), len(b.keys))
+	}
+}
+
+// collidingHash puts every replica of a node on the same few hashes,
+// so nodes collide all the time.
+func collidingHash(data []byte) uint32 {
+	return crc32.ChecksumIEEE(data) % 7
+}
+
+func TestRemove(t *testing.T) {
+	nodes := nodeNames(20)
+	keys := jobNames(1000)
+	m := build(50, nil, nodes...)
+	m.Remove(nodes[3], nodes[11], "not-a-member")
+	rest := make([]string, 0, len(nodes))
+	for i, node := range nodes {
+		if i != 3 && i != 11 {
+			rest = append(rest, node)
+		}
+	}
+	assertSameOwners(t, m, build(50, nil, rest...), keys)
+
+	m.Remove(nodes...)
+	if !m.IsEmpty() || m.Get("job") != "" {
+		t.Fatal("expected empty map after removing all nodes")
+	}
+}
+
+func TestCollisionOrderIndependent(t *testing.T) {
+	nodes := nodeNames(10)
+	keys := jobNames(100)
+	forward := build(3, collidingHash, nodes...)
+	backward := New(3, collidingHash)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		backward.Add(nodes[i])
+	}
+	assertSameOwners(t, forward, backward, keys)
+
+	// the losers of a collision take over the hash on remove.
+	forward.Remove(nodes[0], nodes[5])
+	rest := append(append([]string{}, nodes[1:5]...), nodes[6:]...)
+	assertSameOwners(t, forward, build(3, collidingHash, rest...), keys)
+}
+
+func TestMembers(t *testing.T) {
+	m := build(10, nil, "c", "a", "b", "a")
+	if got := fmt.Sprint(m.Members()); got != "[a b c]" {
+		t.Fatalf("unexpected members %s", got)
+	}
+	m.Remove("b")
+	if got := fmt.Sprint(m.Members()); got != "[a c]" {
+		t.Fatalf("unexpected members %s", got)
+	}
+}
+
+func TestClone(t *testing.T) {
+	keys := jobNames(1000)
+	m := build(50, collidingHash, nodeNames(5)...)
+	c := m.Clone()
+	c.Remove("node-0000")
+	c.Add("node-0005")
+	assertSameOwners(t, m, build(50, collidingHash, nodeNames(5)...), keys)
+	if len(Diff(m, c, keys)) == 0 {
+		t.Fatal("expected clone to change independently")
+	}
+}
+
+func TestGetN(t *testing.T) {
+	m := build(50, nil, nodeNames(5)...)
+	for _, key := range jobNames(100) {
+		items := m.GetN(key, 3)
+		if len(items) != 3 || items[0] != m.Get(key) {
+			t.Fatalf("unexpected items %v of %s", items, key)
+		}
+		if items[0] == items[1] || items[0] == items[2] || items[1] == items[2] {
+			t.Fatalf("expected distinct items, got %v", items)
+		}
+		// the second item takes over the key after the first is removed.
+		c := m.Clone()
+		c.Remove(items[0])
+		if c.Get(key) != items[1] {
+			t.Fatalf("expected %s to take over %s", items[1], key)
+		}
+	}
+	if got := m.GetN("job", 10); len(got) != 5 {
+		t.Fatalf("expected all 5 items, got %v", got)
+	}
+	if got := New(50, nil).GetN("job", 2); got != nil {
+		t.Fatalf("expected nil from empty map, got %v", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	keys := jobNames(1000)
+	old := build(50, nil, nodeNames(5)...)
+	new := old.Clone()
+	new.Add("node-0005")
+	moves := Diff(old, new, keys)
+	if len(moves) == 0 {
+		t.Fatal("expected some keys to move to the new node")
+	}
+	for _, move := range moves {
+		if move.To != "node-0005" || move.From != old.Get(move.Key) {
+			t.Fatalf("unexpected move %+v", move)
+		}
+	}
+	if len(Diff(old, old, keys)) != 0 {
+		t.Fatal("expected no moves between the same map")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	nodes := nodeNames(4)
+	keys := jobNames(1000)
+	report := Analyze(nodes, keys, 50, nil)
+	if report.Keys != 1000 || report.Replicas != 50 || len(report.Nodes) != 4 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	m := build(50, nil, nodes...)
+	var keyShare, ringShare float64
+	for i, share := range report.Nodes {
+		if share.Node != nodes[i] {
+			t.Fatalf("expected node %s, got %s", nodes[i], share.Node)
+		}
+		n := 0
+		for _, key := range keys {
+			if m.Get(key) == share.Node {
+				n++
+			}
+		}
+		if share.Keys != n {
+			t.Fatalf("expected %d keys of %s, got %d", n, share.Node, share.Keys)
+		}
+		keyShare += share.KeyShare
+		ringShare += share.RingShare
+	}
+	if math.Abs(keyShare-1) > 1e-9 || math.Abs(ringShare-1) > 1e-9 {
+		t.Fatalf("expected shares sum to 1, got %v and %v", keyShare, ringShare)
+	}
+	if report.StdDev <= 0 {
+		t.Fatalf("unexpected stddev %v", report.StdDev)
+	}
+	if report := Analyze(nil, keys, 50, nil); len(report.Nodes) != 0 {
+		t.Fatalf("expected no nodes, got %+v", report)
+	}
+}
+
+const (
+	benchNodes    = 1000
+	benchReplicas = 500
+)
+
+func BenchmarkRebuild(b *testing.B) {
+	nodes := nodeNames(benchNodes + 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		build(benchReplicas, nil, nodes...)
+	}
+}
+
+func BenchmarkAddOne(b *testing.B) {
+	nodes := nodeNames(benchNodes + 1)
+	m := build(benchReplicas, nil, nodes[:benchNodes]...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := m.Clone()
+		c.Add(nodes[benchNodes])
+	}
+}
+
+func BenchmarkRemoveOne(b *testing.B) {
+	nodes := nodeNames(benchNodes)
+	m := build(benchReplicas, nil, nodes...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := m.Clone()
+		c.Remove(nodes[i%benchNodes])
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	m := build(benchReplicas, nil, nodeNames(benchNodes)...)
+	keys := jobNames(1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Get(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkDiff(b *testing.B) {
+	nodes := nodeNames(benchNodes + 1)
+	old := build(benchReplicas, nil, nodes[:benchNodes]...)
+	new := old.Clone()
+	new.Add(nodes[benchNodes])
+	keys := jobNames(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Diff(old, new, keys)
+	}
+}
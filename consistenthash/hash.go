@@ -0,0 +1,124 @@
+package consistenthash
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/bits"
+)
+
+// The built-in Hash functions. All nodes of a cluster must use the
+// same Hash function, otherwise they disagree on the owners of jobs.
+var (
+	// CRC32 is the default Hash function.
+	CRC32 Hash = crc32.ChecksumIEEE
+	// FNV1a is the 32-bit FNV-1a hash.
+	FNV1a Hash = fnv1a32
+	// XXHash32 is the 32-bit xxHash with seed 0.
+	XXHash32 Hash = xxhash32
+	// Murmur3 is the 32-bit MurmurHash3 (x86_32) with seed 0.
+	Murmur3 Hash = murmur3
+)
+
+const (
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+func fnv1a32(data []byte) uint32 {
+	h := uint32(fnvOffset32)
+	for _, b := range data {
+		h ^= uint32(b)
+		h *= fnvPrime32
+	}
+	return h
+}
+
+const (
+	xxPrime1 uint32 = 2654435761
+	xxPrime2 uint32 = 2246822519
+	xxPrime3 uint32 = 3266489917
+	xxPrime4 uint32 = 668265263
+	xxPrime5 uint32 = 374761393
+)
+
+func xxRound(v, lane uint32) uint32 {
+	return bits.RotateLeft32(v+lane*xxPrime2, 13) * xxPrime1
+}
+
+func xxhash32(data []byte) uint32 {
+	n := len(data)
+	var h uint32
+	if n >= 16 {
+		p1, p2 := xxPrime1, xxPrime2 // variables to wrap around
+		v1 := p1 + p2
+		v2 := p2
+		v3 := uint32(0)
+		v4 := -p1
+		for ; len(data) >= 16; data = data[16:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint32(data[0:]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint32(data[4:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint32(data[8:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint32(data[12:]))
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) +
+			bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = xxPrime5
+	}
+	h += uint32(n)
+	for ; len(data) >= 4; data = data[4:] {
+		h += binary.LittleEndian.Uint32(data) * xxPrime3
+		h = bits.RotateLeft32(h, 17) * xxPrime4
+	}
+	for _, b := range data {
+		h += uint32(b) * xxPrime5
+		h = bits.RotateLeft32(h, 11) * xxPrime1
+	}
+	h ^= h >> 15
+	h *= xxPrime2
+	h ^= h >> 13
+	h *= xxPrime3
+	h ^= h >> 16
+	return h
+}
+
+const (
+	murmurC1 uint32 = 0xcc9e2d51
+	murmurC2 uint32 = 0x1b873593
+)
+
+func murmur3(data []byte) uint32 {
+	n := len(data)
+	var h uint32
+	for ; len(data) >= 4; data = data[4:] {
+		k := binary.LittleEndian.Uint32(data)
+		k *= murmurC1
+		k = bits.RotateLeft32(k, 15)
+		k *= murmurC2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	var k uint32
+	switch len(data) {
+	case 3:
+		k ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[0])
+		k *= murmurC1
+		k = bits.RotateLeft32(k, 15)
+		k *= murmurC2
+		h ^= k
+	}
+	h ^= uint32(n)
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,65 @@
+package consistenthash
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHashVectors(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   Hash
+		in   string
+		want uint32
+	}{
+		{"fnv1a", FNV1a, "", 0x811c9dc5},
+		{"fnv1a", FNV1a, "a", 0xe40c292c},
+		{"xxhash32", XXHash32, "", 0x02cc5d05},
+		{"xxhash32", XXHash32, "abc", 0x32d153ff},
+		{"xxhash32", XXHash32, "Nobody inspects the spammish repetition", 0xe2293b2f},
+		{"murmur3", Murmur3, "", 0},
+		{"murmur3", Murmur3, "hello", 0x248bfa47},
+		{"murmur3", Murmur3, "The quick brown fox jumps over the lazy dog", 0x2e4ff723},
+	}
+	for _, c := range cases {
+		if got := c.fn([]byte(c.in)); got != c.want {
+			t.Errorf("%s(%q) = %#08x, want %#08x", c.name, c.in, got, c.want)
+		}
+	}
+}
+
+// TestDistribution reports the standard deviation of the jobs
+// assigned to each node, relative to the mean, for job name sets
+// seen in practice.
+func TestDistribution(t *testing.T) {
+	hashes := []struct {
+		name string
+		fn   Hash
+	}{
+		{"crc32", CRC32},
+		{"fnv1a", FNV1a},
+		{"xxhash32", XXHash32},
+		{"murmur3", Murmur3},
+	}
+	jobSets := []string{"job-%d", "report:daily:%d", "tenant-%d-sync", "svc.order.task%03d"}
+	nodes := nodeNames(10)
+	for _, h := range hashes {
+		m := build(50, h.fn, nodes...)
+		for _, jobSet := range jobSets {
+			counts := make(map[string]int)
+			const jobs = 1000
+			for i := 1; i < jobs; i++ {
+				counts[m.Get(fmt.Sprintf(jobSet, i))]++
+			}
+			mean := float64(jobs-1) / float64(len(nodes))
+			var sum float64
+			for _, node := range nodes {
+				d := float64(counts[node]) - mean
+				sum += d * d
+			}
+			stddev := math.Sqrt(sum / float64(len(nodes)))
+			t.Logf("%-8s %-18s stddev=%6.2f (%5.1f%% of mean)", h.name, jobSet, stddev, stddev/mean*100)
+		}
+	}
+}
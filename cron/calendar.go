@@ -0,0 +1,332 @@
+package cron
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendar excludes times from schedules, e.g. holidays and blackout windows.
+type Calendar interface {
+	// Excluded returns true if the schedules should not be activated at t.
+	Excluded(t time.Time) bool
+}
+
+// ExcludingSchedule is a Schedule skipping the activations excluded by Calendar.
+type ExcludingSchedule struct {
+	Schedule Schedule
+	Calendar Calendar
+}
+
+// the maximum of the consecutive activations skipped by ExcludingSchedule.
+const maxExcludedActivations = 1 << 20
+
+// Next returns the next activation time of Schedule which is not excluded
+// by Calendar. If no time can be found within five years, or in the next
+// maxExcludedActivations activations, return the zero time.
+func (s ExcludingSchedule) Next(t time.Time) time.Time {
+	limit := t.AddDate(5, 0, 0)
+	for i := 0; i < maxExcludedActivations; i++ {
+		t = s.Schedule.Next(t)
+		if t.IsZero() || !s.Calendar.Excluded(t) {
+			return t
+		}
+		if t.After(limit) {
+			break
+		}
+	}
+	return time.Time{}
+}
+
+// date is a day in the calendar, without time and location.
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) date {
+	year, month, day := t.Date()
+	return date{year, month, day}
+}
+
+// DateCalendar excludes whole days, e.g. the market holidays.
+// The day of a time is taken in the location of the time.
+type DateCalendar struct {
+	dates map[date]struct{}
+}
+
+// NewDateCalendar creates a DateCalendar excluding the days of dates.
+func NewDateCalendar(dates ...time.Time) *DateCalendar {
+	c := &DateCalendar{dates: make(map[date]struct{}, len(dates))}
+	for _, t := range dates {
+		c.dates[dateOf(t)] = struct{}{}
+	}
+	return c
+}
+
+// ParseDateCalendar creates a DateCalendar excluding the days
+// in the format "2006-01-02".
+func ParseDateCalendar(days ...string) (*DateCalendar, error) {
+	dates := make([]time.Time, 0, len(days))
+	for _, day := range days {
+		t, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse date %s: %s", day, err)
+		}
+		dates = append(dates, t)
+	}
+	return NewDateCalendar(dates...), nil
+}
+
+// Excluded returns true if the day of t is one of the dates.
+func (c *DateCalendar) Excluded(t time.Time) bool {
+	_, ok := c.dates[dateOf(t)]
+	return ok
+}
+
+// WeekendCalendar excludes Saturdays and Sundays.
+type WeekendCalendar struct{}
+
+// Excluded returns true if t is on a weekend.
+func (WeekendCalendar) Excluded(t time.Time) bool {
+	weekday := t.Weekday()
+	return weekday == time.Saturday || weekday == time.Sunday
+}
+
+// ICalCalendar excludes the events of an iCalendar (RFC 5545) file.
+// The all-day events exclude whole days, and the other events exclude
+// the times from DTSTART to DTEND, or to DTSTART plus DURATION. Without
+// both, an all-day event lasts a day and the other events exclude the
+// instant of DTSTART only. Recurring events (RRULE and RDATE) are not
+// supported and fail the parsing.
+type ICalCalendar struct {
+	dates  map[date]struct{}
+	events []icalEvent
+}
+
+type icalEvent struct {
+	start, end time.Time
+}
+
+// LoadICalCalendar creates an ICalCalendar from an .ics file.
+func LoadICalCalendar(path string) (*ICalCalendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseICalCalendar(f)
+}
+
+// ParseICalCalendar creates an ICalCalendar from iCalendar data.
+func ParseICalCalendar(r io.Reader) (*ICalCalendar, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+	c := &ICalCalendar{dates: make(map[date]struct{})}
+	var (
+		inEvent    bool
+		start, end icalTime
+		duration   *icalDuration
+	)
+	for _, line := range lines {
+		name, params, value := splitICalLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent = true
+			start, end, duration = icalTime{}, icalTime{}, nil
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if err := c.addEvent(start, end, duration); err != nil {
+				return nil, err
+			}
+		case inEvent && name == "DTSTART":
+			if start, err = parseICalTime(params, value); err != nil {
+				return nil, err
+			}
+		case inEvent && name == "DTEND":
+			if end, err = parseICalTime(params, value); err != nil {
+				return nil, err
+			}
+		case inEvent && name == "DURATION":
+			d, err := parseICalDuration(value)
+			if err != nil {
+				return nil, err
+			}
+			duration = &d
+		case inEvent && (name == "RRULE" || name == "RDATE"):
+			return nil, fmt.Errorf("recurring events are not supported: %s", line)
+		}
+	}
+	return c, nil
+}
+
+// Excluded returns true if t is in one of the events.
+func (c *ICalCalendar) Excluded(t time.Time) bool {
+	if _, ok := c.dates[dateOf(t)]; ok {
+		return true
+	}
+	for _, event := range c.events {
+		if t.Equal(event.start) || !t.Before(event.start) && t.Before(event.end) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ICalCalendar) addEvent(start, end icalTime, duration *icalDuration) error {
+	if start.t.IsZero() {
+		return fmt.Errorf("event without DTSTART")
+	}
+	if duration != nil {
+		if !end.t.IsZero() {
+			return fmt.Errorf("event at %s with both DTEND and DURATION", start.t)
+		}
+		end = icalTime{t: duration.add(start.t), allDay: start.allDay}
+	}
+	if end.t.IsZero() {
+		// an all-day event lasts a day by default, and the others
+		// end at DTSTART, see RFC 5545 section 3.6.1.
+		end = start
+		if start.allDay {
+			end.t = start.t.AddDate(0, 0, 1)
+		}
+	}
+	if end.t.Before(start.t) {
+		return fmt.Errorf("event at %s with DTEND before DTSTART", start.t)
+	}
+	if !start.allDay {
+		c.events = append(c.events, icalEvent{start.t, end.t})
+		return nil
+	}
+	// the end of an all-day event is exclusive.
+	for day := start.t; day.Before(end.t); day = day.AddDate(0, 0, 1) {
+		c.dates[dateOf(day)] = struct{}{}
+	}
+	return nil
+}
+
+// icalTime is a DTSTART or DTEND value.
+type icalTime struct {
+	t      time.Time
+	allDay bool
+}
+
+// parseICalTime parses the DATE and DATE-TIME values. The DATE-TIME values
+// without UTC suffix or TZID parameter are taken in the local location.
+func parseICalTime(params map[string]string, value string) (icalTime, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.Parse("20060102", value)
+		if err != nil {
+			return icalTime{}, fmt.Errorf("failed to parse date %s: %s", value, err)
+		}
+		return icalTime{t: t, allDay: true}, nil
+	}
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") {
+		loc, value = time.UTC, strings.TrimSuffix(value, "Z")
+	} else if tzid, ok := params["TZID"]; ok {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return icalTime{}, fmt.Errorf("provided bad location %s: %v", tzid, err)
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return icalTime{}, fmt.Errorf("failed to parse date-time %s: %s", value, err)
+	}
+	return icalTime{t: t}, nil
+}
+
+// icalDuration is a DURATION value, the days of which are nominal days.
+type icalDuration struct {
+	days int
+	time time.Duration
+}
+
+func (d icalDuration) add(t time.Time) time.Time {
+	return t.AddDate(0, 0, d.days).Add(d.time)
+}
+
+// parseICalDuration parses the DURATION values like "P1D", "PT2H30M" and
+// "P1W". The negative durations can not end an event, and fail the parsing.
+func parseICalDuration(value string) (icalDuration, error) {
+	var d icalDuration
+	s := strings.TrimPrefix(value, "+")
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return d, fmt.Errorf("failed to parse duration %s", value)
+	}
+	inTime := false
+	for s = s[1:]; s != ""; {
+		if s[0] == 'T' && !inTime {
+			inTime, s = true, s[1:]
+			continue
+		}
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return icalDuration{}, fmt.Errorf("failed to parse duration %s", value)
+		}
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return icalDuration{}, fmt.Errorf("failed to parse duration %s: %s", value, err)
+		}
+		switch unit := s[i]; {
+		case unit == 'W' && !inTime:
+			d.days += 7 * n
+		case unit == 'D' && !inTime:
+			d.days += n
+		case unit == 'H' && inTime:
+			d.time += time.Duration(n) * time.Hour
+		case unit == 'M' && inTime:
+			d.time += time.Duration(n) * time.Minute
+		case unit == 'S' && inTime:
+			d.time += time.Duration(n) * time.Second
+		default:
+			return icalDuration{}, fmt.Errorf("failed to parse duration %s", value)
+		}
+		s = s[i+1:]
+	}
+	return d, nil
+}
+
+// unfoldICalLines returns the content lines, joining the folded ones.
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// splitICalLine splits a content line "NAME;PARAM=VALUE:value".
+func splitICalLine(line string) (name string, params map[string]string, value string) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+	value = line[i+1:]
+	parts := strings.Split(line[:i], ";")
+	name = strings.ToUpper(parts[0])
+	params = make(map[string]string, len(parts)-1)
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return name, params, value
+}
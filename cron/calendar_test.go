@@ -0,0 +1,193 @@
+package cron
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDateCalendar(t *testing.T) {
+	c, err := ParseDateCalendar("2012-07-04", "2012-12-25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		time     string
+		expected bool
+	}{
+		{"Wed Jul 4 00:00 2012", true},
+		{"Wed Jul 4 23:59:59 2012", true},
+		{"Thu Jul 5 00:00 2012", false},
+		{"Tue Dec 25 09:30 2012", true},
+		{"Wed Dec 25 09:30 2013", false},
+		{"2012-07-04T09:00:00+0900", true},
+	}
+	for _, test := range tests {
+		if actual := c.Excluded(getTime(test.time)); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.time, test.expected, actual)
+		}
+	}
+
+	if _, err := ParseDateCalendar("2012-13-01"); err == nil {
+		t.Error("expected error for a bad date")
+	}
+}
+
+func TestWeekendCalendar(t *testing.T) {
+	tests := []struct {
+		time     string
+		expected bool
+	}{
+		{"Fri Jul 6 23:59 2012", false},
+		{"Sat Jul 7 00:00 2012", true},
+		{"Sun Jul 8 12:00 2012", true},
+		{"Mon Jul 9 00:00 2012", false},
+	}
+	for _, test := range tests {
+		if actual := (WeekendCalendar{}).Excluded(getTime(test.time)); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.time, test.expected, actual)
+		}
+	}
+}
+
+const testICal = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Independence Day\r\n" +
+	"DTSTART;VALUE=DATE:20120704\r\n" +
+	"DTEND;VALUE=DATE:20120705\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Christmas holidays\r\n" +
+	"DTSTART;VALUE=DATE:20121224\r\n" +
+	"DTEND;VALUE=DATE:20121227\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Thanksgiving\r\n" +
+	"DTSTART;VALUE=DATE:20121122\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Maintenance window with a folded\r\n" +
+	"  description\r\n" +
+	"DTSTART:20120710T020000Z\r\n" +
+	"DTEND:20120710T0\r\n" +
+	" 40000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Early close\r\n" +
+	"DTSTART;TZID=America/New_York:20121123T130000\r\n" +
+	"DTEND;TZID=America/New_York:20121123T160000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Inventory\r\n" +
+	"DTSTART:20120801T220000Z\r\n" +
+	"DURATION:PT1H30M\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Company retreat\r\n" +
+	"DTSTART;VALUE=DATE:20120913\r\n" +
+	"DURATION:P2D\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Failover drill\r\n" +
+	"DTSTART:20121001T120000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestICalCalendar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.ics")
+	if err := os.WriteFile(path, []byte(testICal), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadICalCalendar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		time     string
+		expected bool
+	}{
+		{"Wed Jul 4 09:00 2012", true},
+		{"Thu Jul 5 09:00 2012", false},
+		{"Mon Dec 24 09:00 2012", true},
+		{"Wed Dec 26 23:00 2012", true},
+		{"Thu Dec 27 00:00 2012", false},
+		{"Thu Nov 22 09:00 2012", true},
+		{"Fri Nov 23 09:00 2012", false},
+		{"2012-07-10T01:59:00+0000", false},
+		{"2012-07-10T02:00:00+0000", true},
+		{"2012-07-10T03:59:00+0000", true},
+		{"2012-07-10T04:00:00+0000", false},
+		{"2012-11-23T12:59:00-0500", false},
+		{"2012-11-23T13:00:00-0500", true},
+		{"2012-11-23T20:59:00+0000", true},
+		{"2012-11-23T21:00:00+0000", false},
+		{"2012-08-01T21:59:00+0000", false},
+		{"2012-08-01T23:29:00+0000", true},
+		{"2012-08-01T23:30:00+0000", false},
+		{"Thu Sep 13 09:00 2012", true},
+		{"Fri Sep 14 23:00 2012", true},
+		{"Sat Sep 15 00:00 2012", false},
+		{"2012-10-01T11:59:00+0000", false},
+		{"2012-10-01T12:00:00+0000", true},
+		{"2012-10-01T12:01:00+0000", false},
+	}
+	for _, test := range tests {
+		if actual := c.Excluded(getTime(test.time)); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.time, test.expected, actual)
+		}
+	}
+}
+
+func TestICalCalendarErrors(t *testing.T) {
+	tests := []struct{ ical, err string }{
+		{"BEGIN:VEVENT\nEND:VEVENT\n", "event without DTSTART"},
+		{"BEGIN:VEVENT\nDTSTART:20120710T020000Z\nDTEND:20120710T010000Z\nEND:VEVENT\n", "DTEND before DTSTART"},
+		{"BEGIN:VEVENT\nDTSTART:20120710T020000Z\nDTEND:20120710T030000Z\nDURATION:PT1H\nEND:VEVENT\n", "both DTEND and DURATION"},
+		{"BEGIN:VEVENT\nDTSTART:20120710T020000Z\nDURATION:PT1D\nEND:VEVENT\n", "failed to parse duration"},
+		{"BEGIN:VEVENT\nDTSTART:20120710T020000Z\nDURATION:-PT1H\nEND:VEVENT\n", "failed to parse duration"},
+		{"BEGIN:VEVENT\nDTSTART;VALUE=DATE:20120101\nRRULE:FREQ=YEARLY\nEND:VEVENT\n", "recurring events are not supported"},
+		{"BEGIN:VEVENT\nDTSTART;VALUE=DATE:2012-07-10\nEND:VEVENT\n", "failed to parse date"},
+		{"BEGIN:VEVENT\nDTSTART:20120710T02\nEND:VEVENT\n", "failed to parse date-time"},
+		{"BEGIN:VEVENT\nDTSTART;TZID=Nowhere/Town:20120710T020000\nEND:VEVENT\n", "provided bad location"},
+	}
+	for _, test := range tests {
+		_, err := ParseICalCalendar(strings.NewReader(test.ical))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q => expected %v, got %v", test.ical, test.err, err)
+		}
+	}
+	if _, err := LoadICalCalendar(filepath.Join(t.TempDir(), "missing.ics")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestExcludingSchedule(t *testing.T) {
+	holidays, err := ParseDateCalendar("2012-07-04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		time, spec string
+		calendar   Calendar
+		expected   string
+	}{
+		{"Mon Jul 2 10:00 2012", "0 0 9 * * *", holidays, "Tue Jul 3 09:00 2012"},
+		{"Tue Jul 3 10:00 2012", "0 0 9 * * *", holidays, "Thu Jul 5 09:00 2012"},
+		{"Fri Jul 6 10:00 2012", "0 0 9 * * *", WeekendCalendar{}, "Mon Jul 9 09:00 2012"},
+		{"Fri Jul 6 10:00 2012", "0 0 9 * * 6,0", WeekendCalendar{}, ""},
+		{"Fri Jul 6 23:59:59 2012", "@every 1s", WeekendCalendar{}, "Mon Jul 9 00:00 2012"},
+	}
+	for _, test := range tests {
+		sched, err := secondParser.Parse(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := ExcludingSchedule{Schedule: sched, Calendar: test.calendar}.Next(getTime(test.time))
+		expected := getTime(test.expected)
+		if !actual.Equal(expected) {
+			t.Errorf("%s, \"%s\": (expected) %v != %v (actual)", test.time, test.spec, expected, actual)
+		}
+	}
+}
@@ -38,16 +38,34 @@ func (c Chain) Then(j Job) Job {
 	return j
 }
 
+// wrapJob returns a Job running j inside around. It is a ScheduledJob if j
+// is one, so the activation times pass through the wrappers to j.
+func wrapJob(j Job, around func(run func())) Job {
+	if sj, ok := j.(ScheduledJob); ok {
+		return scheduledFuncJob(func(activation time.Time) {
+			around(func() { runJob(sj, activation) })
+		})
+	}
+	return FuncJob(func() { around(j.Run) })
+}
+
+// scheduledFuncJob is a FuncJob wanting the activation time of each run.
+type scheduledFuncJob func(activation time.Time)
+
+func (f scheduledFuncJob) Run() { f(time.Time{}) }
+
+func (f scheduledFuncJob) RunScheduled(activation time.Time) { f(activation) }
+
 // Recover panics in wrapped jobs and log them with the provided logger.
 func Recover(logger dlog.Logger) JobWrapper {
 	return func(j Job) Job {
-		return FuncJob(func() {
+		return wrapJob(j, func(run func()) {
 			defer func() {
 				if r := recover(); r != nil {
 					logger.Errorf("panic: stack %v\n%s\n", r, debug.Stack())
 				}
 			}()
-			j.Run()
+			run()
 		})
 	}
 }
@@ -58,14 +76,14 @@ func Recover(logger dlog.Logger) JobWrapper {
 func DelayIfStillRunning(logger dlog.Logger) JobWrapper {
 	return func(j Job) Job {
 		var mu sync.Mutex
-		return FuncJob(func() {
+		return wrapJob(j, func(run func()) {
 			start := time.Now()
 			mu.Lock()
 			defer mu.Unlock()
 			if dur := time.Since(start); dur > time.Minute {
 				logger.Infof("delay duration=%v", dur)
 			}
-			j.Run()
+			run()
 		})
 	}
 }
@@ -76,11 +94,11 @@ func SkipIfStillRunning(logger dlog.Logger) JobWrapper {
 	return func(j Job) Job {
 		var ch = make(chan struct{}, 1)
 		ch <- struct{}{}
-		return FuncJob(func() {
+		return wrapJob(j, func(run func()) {
 			select {
 			case v := <-ch:
 				defer func() { ch <- v }()
-				j.Run()
+				run()
 			default:
 				logger.Infof("skip")
 			}
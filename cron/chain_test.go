@@ -254,3 +254,36 @@ func TestChainSkipIfStillRunning(t *testing.T) {
 	})
 
 }
+
+type scheduledJob struct {
+	mu          sync.Mutex
+	activations []time.Time
+}
+
+func (j *scheduledJob) Run() { j.RunScheduled(time.Time{}) }
+
+func (j *scheduledJob) RunScheduled(activation time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.activations = append(j.activations, activation)
+}
+
+func TestChainScheduledJob(t *testing.T) {
+	var j scheduledJob
+	wrappedJob := NewChain(
+		Recover(DiscardLogger),
+		DelayIfStillRunning(DiscardLogger),
+		SkipIfStillRunning(DiscardLogger)).Then(&j)
+	activation := getTime("2012-07-09T00:01:00+0000")
+	runJob(wrappedJob, activation)
+	wrappedJob.Run()
+	if !reflect.DeepEqual(j.activations, []time.Time{activation, {}}) {
+		t.Error("expected the activation time passed through the chain, got", j.activations)
+	}
+
+	var plain countJob
+	runJob(NewChain(Recover(DiscardLogger)).Then(&plain), activation)
+	if plain.Done() != 1 {
+		t.Error("expected the plain job run, got", plain.Done())
+	}
+}
@@ -0,0 +1,161 @@
+package cron
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/libi/dcron/clock"
+)
+
+// DefaultClockJumpThreshold is a threshold for WithClockJumpPolicy, the
+// least difference between the wall time and the monotonic time passed
+// while Cron sleeps, which is taken as a jump of the wall clock, e.g. NTP
+// stepping the clock or the system resuming from suspend.
+const DefaultClockJumpThreshold = 5 * time.Second
+
+// ClockJumpPolicy decides how the entries are run after a jump of the wall clock.
+type ClockJumpPolicy int
+
+const (
+	// RunOnce runs each entry once for all of the activations it missed
+	// in a forward jump, and runs the entries on their schedules again
+	// from the new time after a backward jump.
+	RunOnce ClockJumpPolicy = iota
+	// RunAll runs each entry once for each activation it missed in a
+	// forward jump, and runs the entries on their schedules again from
+	// the new time after a backward jump.
+	RunAll
+	// Skip skips the activations missed in a forward jump, and keeps the
+	// entries waiting for their next times after a backward jump, so no
+	// wall time is run twice.
+	Skip
+)
+
+func (p ClockJumpPolicy) String() string {
+	switch p {
+	case RunOnce:
+		return "run once"
+	case RunAll:
+		return "run all"
+	case Skip:
+		return "skip"
+	}
+	return "unknown"
+}
+
+// ClockJump describes an entry affected by a jump of the wall clock.
+type ClockJump struct {
+	// Entry is the ID of the affected entry.
+	Entry EntryID
+	// Job is the job of the entry.
+	Job Job
+	// Jump is how far the wall clock jumped, negative if backwards.
+	Jump time.Duration
+	// Policy is the policy applied to the entry.
+	Policy ClockJumpPolicy
+	// Missed is the number of the activations jumped over by the clock.
+	Missed int
+	// Runs is the number of times the entry was run for the jump.
+	Runs int
+	// Next is the next time of the entry after the jump.
+	Next time.Time
+}
+
+// monotonic returns the monotonic time of the clock, zero if it keeps none.
+func (c *Cron) monotonic() time.Duration {
+	if m, ok := c.clock.(clock.Monotonic); ok {
+		return m.Monotonic()
+	}
+	return 0
+}
+
+// wallJump returns how far the wall clock jumped since it was armed at the
+// wall time armed and the monotonic time armedMono. The jumps of a clock
+// keeping no monotonic time are not detected.
+func (c *Cron) wallJump(armed time.Time, armedMono time.Duration) time.Duration {
+	m, ok := c.clock.(clock.Monotonic)
+	if !ok || c.jumpThreshold <= 0 {
+		return 0
+	}
+	elapsed := m.Monotonic() - armedMono
+	return c.now().Round(0).Sub(armed.Round(0)) - elapsed
+}
+
+// jumpedForward runs the entries due at now after the wall clock jumped
+// forward by jump, by the policy. It must be called with entriesMu held.
+func (c *Cron) jumpedForward(now time.Time, jump time.Duration) []ClockJump {
+	var jumps []ClockJump
+	expected := now.Add(-jump)
+	for _, e := range c.dueEntries(now) {
+		// the activations up to the expected wake time are run as usual,
+		// once for all of them like without a jump.
+		onTime := 0
+		var missed []time.Time
+		for t := e.Next; !t.IsZero() && !t.After(now) && onTime+len(missed) < MaxActivations; t = e.Schedule.Next(t) {
+			if t.After(expected) {
+				missed = append(missed, t)
+			} else {
+				onTime++
+			}
+		}
+		activations := []time.Time{e.Next}
+		if len(missed) > 0 {
+			switch c.jumpPolicy {
+			case RunAll:
+				if onTime == 0 {
+					activations = nil
+				}
+				activations = append(activations, missed...)
+			case Skip:
+				if onTime == 0 {
+					activations = nil
+				}
+			}
+		}
+		for _, t := range activations {
+			c.startJob(e.WrappedJob, t)
+		}
+		runs := len(activations)
+		if runs > 0 {
+			e.Prev = e.Next
+		}
+		e.Next = e.Schedule.Next(now)
+		heap.Push(&c.entries, e)
+		c.logger.Infof("run|now=%v, entry=%v, next=%v", now, e.ID, e.Next)
+		if len(missed) > 0 {
+			jumps = append(jumps, ClockJump{Entry: e.ID, Job: e.Job, Jump: jump, Policy: c.jumpPolicy, Missed: len(missed), Runs: runs, Next: e.Next})
+		}
+	}
+	return jumps
+}
+
+// jumpedBack reschedules the entries after the wall clock jumped backward
+// by jump, by the policy. It must be called with entriesMu held.
+func (c *Cron) jumpedBack(now time.Time, jump time.Duration) []ClockJump {
+	var jumps []ClockJump
+	for _, e := range c.entries {
+		next := e.Schedule.Next(now)
+		if next.IsZero() || !next.Before(e.Next) {
+			continue
+		}
+		if c.jumpPolicy != Skip {
+			e.Next = next
+		}
+		jumps = append(jumps, ClockJump{Entry: e.ID, Job: e.Job, Jump: jump, Policy: c.jumpPolicy, Next: e.Next})
+	}
+	heap.Init(&c.entries)
+	return jumps
+}
+
+// reportJumps logs the entries affected by a jump of the wall clock and
+// passes them to the handler. It must be called without entriesMu held, so
+// the handler may use the Cron.
+func (c *Cron) reportJumps(jumps []ClockJump) {
+	for _, j := range jumps {
+		c.logger.Warnf("clock jump|jump=%v, policy=%v, entry=%v, missed=%d, runs=%d, next=%v",
+			j.Jump, j.Policy, j.Entry, j.Missed, j.Runs, j.Next)
+		if c.jumpHandler != nil {
+			c.jumpHandler(j)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package cron
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libi/dcron/clock/clocktest"
+)
+
+func TestClockJump(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    ClockJumpPolicy
+		threshold time.Duration
+		jump      time.Duration
+		runs      int64
+		missed    int
+		next      string
+	}{
+		{"run once", RunOnce, DefaultClockJumpThreshold, time.Hour, 1, 60, "2012-07-09T01:02:00+0000"},
+		{"run all", RunAll, DefaultClockJumpThreshold, time.Hour, 61, 60, "2012-07-09T01:02:00+0000"},
+		{"skip", Skip, DefaultClockJumpThreshold, time.Hour, 1, 60, "2012-07-09T01:02:00+0000"},
+		{"disabled", RunAll, 0, time.Hour, 1, 0, "2012-07-09T01:02:00+0000"},
+		{"below threshold", RunAll, time.Minute, 30 * time.Second, 1, 0, "2012-07-09T00:02:00+0000"},
+		{"back run once", RunOnce, DefaultClockJumpThreshold, -time.Hour, 0, 0, "2012-07-08T23:02:00+0000"},
+		{"back skip", Skip, DefaultClockJumpThreshold, -time.Hour, 0, 0, "2012-07-09T00:01:00+0000"},
+	}
+	for _, test := range tests {
+		fake := clocktest.NewFake(getTime("2012-07-09T00:00:30+0000"))
+		jumps := make(chan ClockJump, 1)
+		cron := New(WithLocation(time.UTC), WithClock(fake),
+			WithClockJumpPolicy(test.policy, test.threshold),
+			WithClockJumpHandler(func(j ClockJump) { jumps <- j }))
+		var runs int64
+		cron.AddFunc("* * * * *", func() { atomic.AddInt64(&runs, 1) })
+		cron.Start()
+
+		// the job is due at 00:01, in 30s.
+		fake.BlockUntil(1)
+		fake.Set(fake.Now().Add(test.jump))
+		fake.Advance(30 * time.Second)
+		var jump ClockJump
+		if test.missed > 0 || test.runs == 0 {
+			select {
+			case jump = <-jumps:
+			case <-time.After(OneSecond):
+				t.Fatalf("%s: expected a clock jump", test.name)
+			}
+		}
+		fake.BlockUntil(1)
+		next := cron.Entries()[0].Next
+		<-cron.Stop().Done()
+
+		if jump.Missed != test.missed {
+			t.Errorf("%s: expected %d missed, got %d", test.name, test.missed, jump.Missed)
+		}
+		if jump.Missed > 0 && (jump.Jump != test.jump || jump.Policy != test.policy || jump.Runs != int(test.runs)) {
+			t.Errorf("%s: unexpected clock jump %+v", test.name, jump)
+		}
+		if r := atomic.LoadInt64(&runs); r != test.runs {
+			t.Errorf("%s: expected %d runs, got %d", test.name, test.runs, r)
+		}
+		if !next.Equal(getTime(test.next)) {
+			t.Errorf("%s: expected next %s, got %v", test.name, test.next, next)
+		}
+		select {
+		case j := <-jumps:
+			t.Errorf("%s: unexpected clock jump %+v", test.name, j)
+		default:
+		}
+	}
+}
+
+// A jump is detected when an entry added after it wakes Cron first, before
+// the timer armed before the jump fires. The runs of RunAll are given the
+// activation times they missed.
+func TestClockJumpOnWake(t *testing.T) {
+	fake := clocktest.NewFake(getTime("2012-07-09T00:00:30+0000"))
+	jumps := make(chan ClockJump, 1)
+	cron := New(WithLocation(time.UTC), WithClock(fake),
+		WithClockJumpPolicy(RunAll, DefaultClockJumpThreshold),
+		WithClockJumpHandler(func(j ClockJump) { jumps <- j }))
+	var job scheduledJob
+	id, _ := cron.AddJob("* * * * *", &job)
+	cron.Start()
+
+	fake.BlockUntil(1)
+	fake.Set(fake.Now().Add(time.Hour))
+	cron.AddFunc("@daily", func() {})
+	var jump ClockJump
+	select {
+	case jump = <-jumps:
+	case <-time.After(OneSecond):
+		t.Fatal("expected a clock jump")
+	}
+	if jump.Entry != id || jump.Jump != time.Hour || jump.Missed != 60 || jump.Runs != 60 {
+		t.Errorf("unexpected clock jump %+v", jump)
+	}
+	if expected := getTime("2012-07-09T01:01:00+0000"); !jump.Next.Equal(expected) {
+		t.Errorf("expected next %v, got %v", expected, jump.Next)
+	}
+	<-cron.Stop().Done()
+	activations := map[int64]bool{}
+	for _, a := range job.activations {
+		activations[a.Unix()] = true
+	}
+	if len(job.activations) != 60 || len(activations) != 60 ||
+		!activations[getTime("2012-07-09T00:01:00+0000").Unix()] || !activations[getTime("2012-07-09T01:00:00+0000").Unix()] {
+		t.Errorf("expected the 60 missed activations run, got %v", job.activations)
+	}
+}
+
+// Without WithClockJumpPolicy the entries keep their times after a backward
+// jump, so no wall time is run twice.
+func TestClockJumpOffByDefault(t *testing.T) {
+	fake := clocktest.NewFake(getTime("2012-07-09T00:00:30+0000"))
+	cron := New(WithLocation(time.UTC), WithClock(fake))
+	var runs int64
+	cron.AddFunc("* * * * *", func() { atomic.AddInt64(&runs, 1) })
+	cron.Start()
+
+	fake.BlockUntil(1)
+	fake.Set(fake.Now().Add(-time.Hour))
+	fake.Advance(30 * time.Second)
+	fake.BlockUntil(1)
+	next := cron.Entries()[0].Next
+	<-cron.Stop().Done()
+
+	if r := atomic.LoadInt64(&runs); r != 0 {
+		t.Errorf("expected no runs, got %d", r)
+	}
+	if expected := getTime("2012-07-09T00:01:00+0000"); !next.Equal(expected) {
+		t.Errorf("expected next %v, got %v", expected, next)
+	}
+}
@@ -0,0 +1,165 @@
+package cron
+
+import "time"
+
+// the maximum of the activations skipped by the schedules searching for a
+// matching activation, like IntersectSchedule and HoursSchedule.
+const maxSkippedActivations = 1 << 20
+
+// UnionSchedule activates at the activations of any of its Schedules.
+type UnionSchedule struct {
+	Schedules []Schedule
+}
+
+// Union returns a Schedule activating at the activations of any of schedules.
+func Union(schedules ...Schedule) UnionSchedule {
+	return UnionSchedule{Schedules: schedules}
+}
+
+// Next returns the earliest next activation time of the Schedules.
+func (s UnionSchedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, schedule := range s.Schedules {
+		n := schedule.Next(t)
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+// IntersectSchedule activates at the activations shared by all of its Schedules.
+type IntersectSchedule struct {
+	Schedules []Schedule
+}
+
+// Intersect returns a Schedule activating at the activations shared by all of schedules.
+func Intersect(schedules ...Schedule) IntersectSchedule {
+	return IntersectSchedule{Schedules: schedules}
+}
+
+// Next returns the next activation time shared by the Schedules. If no time
+// can be found within five years, return the zero time.
+func (s IntersectSchedule) Next(t time.Time) time.Time {
+	if len(s.Schedules) == 0 {
+		return time.Time{}
+	}
+	limit := t.AddDate(5, 0, 0)
+	for i := 0; i < maxSkippedActivations; i++ {
+		// the latest of the next activations is the earliest candidate,
+		// the Schedules activating before it are searched from it.
+		var next time.Time
+		agreed := true
+		for _, schedule := range s.Schedules {
+			n := schedule.Next(t)
+			if n.IsZero() {
+				return time.Time{}
+			}
+			if !next.IsZero() && !n.Equal(next) {
+				agreed = false
+			}
+			if n.After(next) {
+				next = n
+			}
+		}
+		if agreed {
+			return next
+		}
+		if next.After(limit) {
+			break
+		}
+		t = next.Add(-time.Nanosecond)
+	}
+	return time.Time{}
+}
+
+// WindowSchedule activates at the activations of Schedule within [Start, End).
+// The zero Start or End leaves the window open on that side.
+type WindowSchedule struct {
+	Schedule   Schedule
+	Start, End time.Time
+}
+
+// Between returns a Schedule activating at the activations of s from start
+// until end, exclusive. The zero start or end leaves the window open on that side.
+func Between(s Schedule, start, end time.Time) WindowSchedule {
+	return WindowSchedule{Schedule: s, Start: start, End: end}
+}
+
+// Next returns the next activation time of Schedule within the window,
+// or the zero time if there is none.
+func (s WindowSchedule) Next(t time.Time) time.Time {
+	if !s.Start.IsZero() && t.Before(s.Start) {
+		t = s.Start.Add(-time.Nanosecond)
+	}
+	next := s.Schedule.Next(t)
+	if !s.End.IsZero() && !next.Before(s.End) {
+		return time.Time{}
+	}
+	return next
+}
+
+// HoursSchedule activates at the activations of Schedule whose hour of day
+// is in [From, To), in the location of the activation. If From is greater
+// than To, the window wraps past midnight, e.g. 22 to 6.
+type HoursSchedule struct {
+	Schedule Schedule
+	From, To int
+}
+
+// DuringHours returns a Schedule activating at the activations of s whose
+// hour of day is from the hour from until the hour to, exclusive. The hours
+// are in 0-24, and from greater than to wraps past midnight.
+func DuringHours(s Schedule, from, to int) HoursSchedule {
+	return HoursSchedule{Schedule: s, From: from, To: to}
+}
+
+// Next returns the next activation time of Schedule within the hours.
+// If no time can be found within five years, return the zero time.
+func (s HoursSchedule) Next(t time.Time) time.Time {
+	limit := t.AddDate(5, 0, 0)
+	for i := 0; i < maxSkippedActivations; i++ {
+		t = s.Schedule.Next(t)
+		if t.IsZero() || s.contains(t.Hour()) {
+			return t
+		}
+		if t.After(limit) {
+			break
+		}
+		// skip the activations until the window opens again.
+		open := time.Date(t.Year(), t.Month(), t.Day(), s.From, 0, 0, 0, t.Location())
+		if !open.After(t) {
+			open = open.AddDate(0, 0, 1)
+		}
+		t = open.Add(-time.Nanosecond)
+	}
+	return time.Time{}
+}
+
+func (s HoursSchedule) contains(hour int) bool {
+	if s.From <= s.To {
+		return s.From <= hour && hour < s.To
+	}
+	return hour >= s.From || hour < s.To
+}
+
+// OffsetSchedule shifts every activation of Schedule by Offset.
+type OffsetSchedule struct {
+	Schedule Schedule
+	Offset   time.Duration
+}
+
+// Offset returns a Schedule shifting every activation of s by d,
+// e.g. Offset(s, -5*time.Minute) activates 5 minutes early.
+func Offset(s Schedule, d time.Duration) OffsetSchedule {
+	return OffsetSchedule{Schedule: s, Offset: d}
+}
+
+// Next returns the next shifted activation time after t.
+func (s OffsetSchedule) Next(t time.Time) time.Time {
+	next := s.Schedule.Next(t.Add(-s.Offset))
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(s.Offset)
+}
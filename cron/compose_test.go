@@ -0,0 +1,62 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComposedSchedules(t *testing.T) {
+	parse := func(spec string) Schedule {
+		s, err := secondParser.Parse(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	weekdays := parse("0 0 9 * * 1-5")
+	weekends := parse("0 0 12 * * 0,6")
+	hourly := parse("0 0 * * * *")
+	halfHourly := parse("0 */30 * * * *")
+	daily := parse("0 0 9 * * *")
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		time     string
+		expected string
+	}{
+		{"union first", Union(weekdays, weekends), "Fri Jul 6 08:00 2012", "Fri Jul 6 09:00 2012"},
+		{"union second", Union(weekdays, weekends), "Fri Jul 6 10:00 2012", "Sat Jul 7 12:00 2012"},
+		{"union empty", Union(), "Fri Jul 6 10:00 2012", ""},
+
+		{"intersect", Intersect(daily, parse("0 0 * 13 * *")), "Fri Jul 6 10:00 2012", "Fri Jul 13 09:00 2012"},
+		{"intersect steps", Intersect(parse("0 */15 * * * *"), parse("0 */20 * * * *")), "Fri Jul 6 10:01 2012", "Fri Jul 6 11:00 2012"},
+		{"intersect never", Intersect(daily, parse("0 0 10 * * *")), "Fri Jul 6 10:00 2012", ""},
+
+		{"between before", Between(hourly, getTime("Mon Jul 9 00:00 2012"), getTime("Tue Jul 10 00:00 2012")), "Sun Jul 1 10:00 2012", "Mon Jul 9 00:00 2012"},
+		{"between within", Between(hourly, getTime("Mon Jul 9 00:00 2012"), getTime("Tue Jul 10 00:00 2012")), "Mon Jul 9 12:30 2012", "Mon Jul 9 13:00 2012"},
+		{"between after", Between(hourly, getTime("Mon Jul 9 00:00 2012"), getTime("Tue Jul 10 00:00 2012")), "Mon Jul 9 23:30 2012", ""},
+		{"between open start", Between(hourly, time.Time{}, getTime("Tue Jul 10 00:00 2012")), "Sun Jul 1 10:30 2012", "Sun Jul 1 11:00 2012"},
+		{"between open end", Between(hourly, getTime("Mon Jul 9 00:00 2012"), time.Time{}), "Mon Jul 9 23:30 2012", "Tue Jul 10 00:00 2012"},
+
+		{"hours before", DuringHours(halfHourly, 9, 17), "Fri Jul 6 08:00 2012", "Fri Jul 6 09:00 2012"},
+		{"hours within", DuringHours(halfHourly, 9, 17), "Fri Jul 6 16:00 2012", "Fri Jul 6 16:30 2012"},
+		{"hours after", DuringHours(halfHourly, 9, 17), "Fri Jul 6 16:45 2012", "Sat Jul 7 09:00 2012"},
+		{"hours overnight", DuringHours(halfHourly, 22, 6), "Fri Jul 6 05:45 2012", "Fri Jul 6 22:00 2012"},
+		{"hours overnight within", DuringHours(halfHourly, 22, 6), "Fri Jul 6 23:45 2012", "Sat Jul 7 00:00 2012"},
+		{"hours never", DuringHours(daily, 12, 17), "Fri Jul 6 10:00 2012", ""},
+
+		{"offset early", Offset(daily, -5*time.Minute), "Fri Jul 6 08:50 2012", "Fri Jul 6 08:55 2012"},
+		{"offset early passed", Offset(daily, -5*time.Minute), "Fri Jul 6 08:56 2012", "Sat Jul 7 08:55 2012"},
+		{"offset late", Offset(daily, 90*time.Minute), "Fri Jul 6 10:00 2012", "Fri Jul 6 10:30 2012"},
+
+		{"nested", Offset(Union(weekdays, weekends), time.Hour), "Fri Jul 6 10:30 2012", "Sat Jul 7 13:00 2012"},
+	}
+	for _, test := range tests {
+		actual := test.schedule.Next(getTime(test.time))
+		expected := getTime(test.expected)
+		if !actual.Equal(expected) {
+			t.Errorf("%s: %s, expected %v, got %v", test.name, test.time, expected, actual)
+		}
+	}
+}
@@ -3,7 +3,7 @@ package cron
 import "time"
 
 // ConstantDelaySchedule represents a simple recurring duty cycle, e.g. "Every 5 minutes".
-// It does not support jobs more frequent than once a second.
+// It supports jobs more frequent than once a second only if it is created by EveryPrecise.
 type ConstantDelaySchedule struct {
 	Delay time.Duration
 }
@@ -20,8 +20,44 @@ func Every(duration time.Duration) ConstantDelaySchedule {
 	}
 }
 
+// EveryPrecise returns a crontab Schedule that activates once every duration,
+// without rounding the duration to the second, e.g. every 200 milliseconds.
+// Delays of less than a millisecond are not supported (will round up to 1 millisecond).
+func EveryPrecise(duration time.Duration) ConstantDelaySchedule {
+	if duration < time.Millisecond {
+		duration = time.Millisecond
+	}
+	return ConstantDelaySchedule{
+		Delay: duration,
+	}
+}
+
 // Next returns the next time this should be run.
 // This rounds so that the next activation time will be on the second.
+// If the delay is not a whole number of seconds, the activation times
+// are aligned to the multiples of the delay instead, so they do not
+// drift with the delays of waking up.
 func (schedule ConstantDelaySchedule) Next(t time.Time) time.Time {
+	if schedule.Delay%time.Second != 0 {
+		return t.Truncate(schedule.Delay).Add(schedule.Delay)
+	}
 	return t.Add(schedule.Delay - time.Duration(t.Nanosecond())*time.Nanosecond)
 }
+
+// Prev returns the previous time this should have run, the inverse of Next:
+// Next(Prev(t)) is t for the times on the second, or on the multiples of the
+// delay if it is not a whole number of seconds.
+func (schedule ConstantDelaySchedule) Prev(t time.Time) time.Time {
+	if schedule.Delay%time.Second != 0 {
+		prev := t.Truncate(schedule.Delay)
+		if prev.Equal(t) {
+			prev = prev.Add(-schedule.Delay)
+		}
+		return prev
+	}
+	t = t.Add(-schedule.Delay)
+	if t.Nanosecond() > 0 {
+		t = t.Add(time.Second - time.Duration(t.Nanosecond())*time.Nanosecond)
+	}
+	return t
+}
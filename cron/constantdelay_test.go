@@ -52,3 +52,57 @@ func TestConstantDelayNext(t *testing.T) {
 		}
 	}
 }
+
+func TestEveryPreciseNext(t *testing.T) {
+	tests := []struct {
+		time     string
+		delay    time.Duration
+		expected string
+	}{
+		// Aligned to the multiples of the delay
+		{"Mon Jul 9 14:45:00 2012", 250 * time.Millisecond, "Mon Jul 9 14:45:00.25 2012"},
+		{"Mon Jul 9 14:45:00.1 2012", 250 * time.Millisecond, "Mon Jul 9 14:45:00.25 2012"},
+		{"Mon Jul 9 14:45:00.25 2012", 250 * time.Millisecond, "Mon Jul 9 14:45:00.5 2012"},
+		{"Mon Jul 9 14:45:00.999 2012", 200 * time.Millisecond, "Mon Jul 9 14:45:01 2012"},
+
+		// Wrap around minute, hour, day, month, and year
+		{"Mon Dec 31 23:59:59.9 2012", 200 * time.Millisecond, "Tue Jan 1 00:00:00 2013"},
+
+		// Round up to 1 millisecond if the duration is less.
+		{"Mon Jul 9 14:45:00 2012", 15 * time.Microsecond, "Mon Jul 9 14:45:00.001 2012"},
+
+		// Whole seconds are rounded on the second like Every.
+		{"Mon Jul 9 14:45:00.005 2012", 15 * time.Minute, "Mon Jul 9 15:00 2012"},
+	}
+
+	for _, c := range tests {
+		actual := EveryPrecise(c.delay).Next(getTime(c.time))
+		expected := getTime(c.expected)
+		if !actual.Equal(expected) {
+			t.Errorf("%s, \"%s\": (expected) %v != %v (actual)", c.time, c.delay, expected, actual)
+		}
+	}
+}
+
+func TestConstantDelayPrev(t *testing.T) {
+	tests := []struct {
+		time     string
+		schedule ConstantDelaySchedule
+		expected string
+	}{
+		{"Mon Jul 9 14:45 2012", Every(15 * time.Minute), "Mon Jul 9 14:30 2012"},
+		{"Mon Jul 9 14:45:00.005 2012", Every(15 * time.Minute), "Mon Jul 9 14:30:01 2012"},
+		{"Mon Jul 9 14:45:00.005 2012", Every(time.Second), "Mon Jul 9 14:45:00 2012"},
+		{"Tue Jan 1 00:00 2013", Every(time.Hour), "Mon Dec 31 23:00 2012"},
+		{"Mon Jul 9 14:45:00.5 2012", EveryPrecise(250 * time.Millisecond), "Mon Jul 9 14:45:00.25 2012"},
+		{"Mon Jul 9 14:45:00.6 2012", EveryPrecise(250 * time.Millisecond), "Mon Jul 9 14:45:00.5 2012"},
+	}
+
+	for _, c := range tests {
+		actual := c.schedule.Prev(getTime(c.time))
+		expected := getTime(c.expected)
+		if !actual.Equal(expected) {
+			t.Errorf("%s, \"%s\": (expected) %v != %v (actual)", c.time, c.schedule.Delay, expected, actual)
+		}
+	}
+}
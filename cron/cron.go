@@ -1,31 +1,38 @@
 package cron
 
 import (
+	"container/heap"
 	"context"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/dcron-contrib/commons/dlog"
+	"github.com/libi/dcron/clock"
 )
 
 // Cron keeps track of any number of entries, invoking the associated func as
 // specified by the schedule. It may be started, stopped, and the entries may
 // be inspected while running.
 type Cron struct {
-	entries   []*Entry
+	entries   entryHeap          // guarded by entriesMu
+	index     map[EntryID]*Entry // guarded by entriesMu
+	entriesMu sync.Mutex
 	chain     Chain
 	stop      chan struct{}
-	add       chan *Entry
-	remove    chan EntryID
-	snapshot  chan chan []Entry
+	wake      chan struct{} // the entries changed while running
 	running   bool
 	logger    dlog.Logger
 	runningMu sync.Mutex
 	location  *time.Location
 	parser    ScheduleParser
+	clock     clock.Clock
 	nextID    EntryID
 	jobWaiter sync.WaitGroup
+
+	jumpPolicy    ClockJumpPolicy
+	jumpThreshold time.Duration
+	jumpHandler   func(ClockJump)
 }
 
 // ScheduleParser is an interface for schedule spec parsers that return a Schedule
@@ -33,11 +40,45 @@ type ScheduleParser interface {
 	Parse(spec string) (Schedule, error)
 }
 
+// KeyedScheduleParser is a ScheduleParser which can also resolve the hashed
+// "H" fields of a spec by a key, e.g. the job name. Parser implements it.
+type KeyedScheduleParser interface {
+	ScheduleParser
+	ParseWithKey(spec, key string) (Schedule, error)
+}
+
+// DescribingParser is a ScheduleParser which can also describe a spec in
+// words, with the hashed "H" fields resolved by a key. Parser implements it.
+type DescribingParser interface {
+	ScheduleParser
+	DescribeWithKey(spec, key string, locale Locale) (string, error)
+}
+
 // Job is an interface for submitted cron jobs.
 type Job interface {
 	Run()
 }
 
+// ScheduledJob is a Job which wants the activation time of each run, e.g.
+// to tell its runs apart from another process running the same schedule.
+// Cron calls RunScheduled instead of Run, and the wrappers of this package
+// pass the activation time through, a JobWrapper returning a plain Job
+// makes Cron call Run.
+type ScheduledJob interface {
+	Job
+	RunScheduled(activation time.Time)
+}
+
+// runJob runs j for its activation at activation, Run is called if j is
+// not a ScheduledJob or the activation time is unknown.
+func runJob(j Job, activation time.Time) {
+	if sj, ok := j.(ScheduledJob); ok && !activation.IsZero() {
+		sj.RunScheduled(activation)
+		return
+	}
+	j.Run()
+}
+
 // Schedule describes a job's duty cycle.
 type Schedule interface {
 	// Next returns the next activation time, later than the given time.
@@ -45,6 +86,31 @@ type Schedule interface {
 	Next(time.Time) time.Time
 }
 
+// PrevSchedule is a Schedule which can also walk backwards, e.g. for
+// catch-up and backfills. SpecSchedule and ConstantDelaySchedule implement it.
+type PrevSchedule interface {
+	Schedule
+	// Prev returns the previous activation time, earlier than the given time.
+	Prev(time.Time) time.Time
+}
+
+// MaxActivations is the maximum of the activations returned by ActivationsBetween.
+const MaxActivations = 100000
+
+// ActivationsBetween returns the activation times of schedule from the time
+// from until the time to, exclusive, e.g. the ticks missed while a node was
+// down. At most MaxActivations times are returned.
+func ActivationsBetween(schedule Schedule, from, to time.Time) []time.Time {
+	var times []time.Time
+	for t := schedule.Next(from.Add(-time.Nanosecond)); !t.IsZero() && t.Before(to); t = schedule.Next(t) {
+		if len(times) == MaxActivations {
+			break
+		}
+		times = append(times, t)
+	}
+	return times
+}
+
 // EntryID identifies an entry within a Cron instance
 type EntryID int
 
@@ -71,6 +137,9 @@ type Entry struct {
 	// It is kept around so that user code that needs to get at the job later,
 	// e.g. via Entries() can do so.
 	Job Job
+
+	// the index of the entry in the entry heap.
+	index int
 }
 
 // Valid returns true if this is not the zero entry.
@@ -111,20 +180,24 @@ func (s byTime) Less(i, j int) bool {
 //	  Description: Wrap submitted jobs to customize behavior.
 //	  Default:     A chain that recovers panics and logs them to stderr.
 //
+//	Clock
+//	  Description: The source of the current time and the timers.
+//	  Default:     The system time
+//
 // See "cron.With*" to modify the default behavior.
 func New(opts ...Option) *Cron {
 	c := &Cron{
 		entries:   nil,
+		index:     make(map[EntryID]*Entry),
 		chain:     NewChain(),
-		add:       make(chan *Entry),
 		stop:      make(chan struct{}),
-		snapshot:  make(chan chan []Entry),
-		remove:    make(chan EntryID),
+		wake:      make(chan struct{}, 1),
 		running:   false,
 		runningMu: sync.Mutex{},
 		logger:    DefaultLogger,
 		location:  time.Local,
 		parser:    standardParser,
+		clock:     clock.Real(),
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -155,6 +228,11 @@ func (c *Cron) AddJob(spec string, cmd Job) (EntryID, error) {
 	return c.Schedule(schedule, cmd), nil
 }
 
+// Parser returns the parser of the schedule specs in AddFunc and AddJob.
+func (c *Cron) Parser() ScheduleParser {
+	return c.parser
+}
+
 // Schedule adds a Job to the Cron to be run on the given schedule.
 // The job is wrapped with the configured Chain.
 func (c *Cron) Schedule(schedule Schedule, cmd Job) EntryID {
@@ -167,23 +245,23 @@ func (c *Cron) Schedule(schedule Schedule, cmd Job) EntryID {
 		WrappedJob: c.chain.Then(cmd),
 		Job:        cmd,
 	}
-	if !c.running {
-		c.entries = append(c.entries, entry)
-	} else {
-		c.add <- entry
+	c.entriesMu.Lock()
+	defer c.entriesMu.Unlock()
+	if c.running {
+		now := c.now()
+		entry.Next = entry.Schedule.Next(now)
+		c.logger.Infof("added|now=%v, entry=%v, next=%v", now, entry.ID, entry.Next)
+		c.wakeUp()
 	}
+	heap.Push(&c.entries, entry)
+	c.index[entry.ID] = entry
 	return entry.ID
 }
 
-// Entries returns a snapshot of the cron entries.
+// Entries returns a snapshot of the cron entries, sorted by their next times.
 func (c *Cron) Entries() []Entry {
-	c.runningMu.Lock()
-	defer c.runningMu.Unlock()
-	if c.running {
-		replyChan := make(chan []Entry, 1)
-		c.snapshot <- replyChan
-		return <-replyChan
-	}
+	c.entriesMu.Lock()
+	defer c.entriesMu.Unlock()
 	return c.entrySnapshot()
 }
 
@@ -194,10 +272,10 @@ func (c *Cron) Location() *time.Location {
 
 // Entry returns a snapshot of the given entry, or nil if it couldn't be found.
 func (c *Cron) Entry(id EntryID) Entry {
-	for _, entry := range c.Entries() {
-		if id == entry.ID {
-			return entry
-		}
+	c.entriesMu.Lock()
+	defer c.entriesMu.Unlock()
+	if entry, ok := c.index[id]; ok {
+		return *entry
 	}
 	return Entry{}
 }
@@ -206,10 +284,12 @@ func (c *Cron) Entry(id EntryID) Entry {
 func (c *Cron) Remove(id EntryID) {
 	c.runningMu.Lock()
 	defer c.runningMu.Unlock()
+	c.entriesMu.Lock()
+	defer c.entriesMu.Unlock()
+	c.removeEntry(id)
 	if c.running {
-		c.remove <- id
-	} else {
-		c.removeEntry(id)
+		c.logger.Infof("removed|entry=%v", id)
+		c.wakeUp()
 	}
 }
 
@@ -242,82 +322,115 @@ func (c *Cron) run() {
 	c.logger.Infof("start")
 
 	// Figure out the next activation times for each entry.
+	c.entriesMu.Lock()
 	now := c.now()
 	for _, entry := range c.entries {
 		entry.Next = entry.Schedule.Next(now)
 		c.logger.Infof("schedule|now=%v, entry=%v, next=%v", now, entry.ID, entry.Next)
 	}
+	heap.Init(&c.entries)
+	c.entriesMu.Unlock()
 
 	for {
 		// Determine the next entry to run.
-		sort.Sort(byTime(c.entries))
-
-		var timer *time.Timer
-		if len(c.entries) == 0 || c.entries[0].Next.IsZero() {
-			// If there are no entries yet, just sleep - it still handles new entries
-			// and stop requests.
-			timer = time.NewTimer(100000 * time.Hour)
-		} else {
-			timer = time.NewTimer(c.entries[0].Next.Sub(now))
+		c.entriesMu.Lock()
+		// the time passed since now is not negligible
+		// for the sub-second activations.
+		armed, armedMono := c.now(), c.monotonic()
+		wait := 100000 * time.Hour
+		if len(c.entries) > 0 && !c.entries[0].Next.IsZero() {
+			wait = c.entries[0].Next.Sub(armed)
+		}
+		// If there are no entries yet, just sleep - it still handles new entries
+		// and stop requests.
+		timer := c.clock.NewTimer(wait)
+		c.entriesMu.Unlock()
+
+		select {
+		case now = <-timer.C():
+			now = now.In(c.location)
+			c.logger.Infof("wake|now=%v", now)
+
+		case <-c.wake:
+			// an entry is added or removed, the next entry may change.
+			timer.Stop()
+			now = c.now()
+
+		case <-c.stop:
+			timer.Stop()
+			return
 		}
 
-		for {
-			select {
-			case now = <-timer.C:
-				now = now.In(c.location)
-				c.logger.Infof("wake|now=%v", now)
-
-				// Run every entry whose next time was less than now
-				for _, e := range c.entries {
-					if e.Next.After(now) || e.Next.IsZero() {
-						break
-					}
-					c.startJob(e.WrappedJob)
-					e.Prev = e.Next
-					e.Next = e.Schedule.Next(now)
-					c.logger.Infof("run|now=%v, entry=%v, next=%v", now, e.ID, e.Next)
-				}
-
-			case newEntry := <-c.add:
-				timer.Stop()
-				now = c.now()
-				newEntry.Next = newEntry.Schedule.Next(now)
-				c.entries = append(c.entries, newEntry)
-				c.logger.Infof("added|now=%v, entry=%v, next=%v", now, newEntry.ID, newEntry.Next)
-
-			case replyChan := <-c.snapshot:
-				replyChan <- c.entrySnapshot()
-				continue
-
-			case <-c.stop:
-				timer.Stop()
-				c.logger.Infof("stop")
-				return
-
-			case id := <-c.remove:
-				timer.Stop()
-				now = c.now()
-				c.removeEntry(id)
-				c.logger.Infof("removed|entry=%v", id)
+		// The timer runs on the monotonic time, the wall clock jumped if it
+		// moved otherwise, e.g. stepped by NTP or suspended. It is checked
+		// on every wake, before the timer is armed again from the new time.
+		jump := c.wallJump(armed, armedMono)
+		var jumps []ClockJump
+		c.entriesMu.Lock()
+		if c.jumpThreshold > 0 && jump >= c.jumpThreshold {
+			jumps = c.jumpedForward(now, jump)
+		} else {
+			// Run every entry whose next time was less than now
+			due := c.dueEntries(now)
+			for _, e := range due {
+				c.startJob(e.WrappedJob, e.Next)
 			}
+			c.reschedule(due, now)
+			if c.jumpThreshold > 0 && jump <= -c.jumpThreshold {
+				jumps = c.jumpedBack(now, jump)
+			}
+		}
+		c.entriesMu.Unlock()
+		c.reportJumps(jumps)
+	}
+}
 
+// dueEntries pops the entries whose next time is not after now, in the
+// order of their next times. It must be called with entriesMu held.
+func (c *Cron) dueEntries(now time.Time) []*Entry {
+	var due []*Entry
+	for len(c.entries) > 0 {
+		e := c.entries[0]
+		if e.Next.After(now) || e.Next.IsZero() {
 			break
 		}
+		due = append(due, heap.Pop(&c.entries).(*Entry))
 	}
+	return due
 }
 
-// startJob runs the given job in a new goroutine.
-func (c *Cron) startJob(j Job) {
+// reschedule pushes the entries run at now back with their next times.
+// It must be called with entriesMu held.
+func (c *Cron) reschedule(entries []*Entry, now time.Time) {
+	for _, e := range entries {
+		e.Prev = e.Next
+		e.Next = e.Schedule.Next(now)
+		heap.Push(&c.entries, e)
+		c.logger.Infof("run|now=%v, entry=%v, next=%v", now, e.ID, e.Next)
+	}
+}
+
+// wakeUp makes the run loop wake up to find the next entry again.
+func (c *Cron) wakeUp() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// startJob runs the given job for its activation at activation in a new
+// goroutine.
+func (c *Cron) startJob(j Job, activation time.Time) {
 	c.jobWaiter.Add(1)
 	go func() {
 		defer c.jobWaiter.Done()
-		j.Run()
+		runJob(j, activation)
 	}()
 }
 
 // now returns current time in c location
 func (c *Cron) now() time.Time {
-	return time.Now().In(c.location)
+	return c.clock.Now().In(c.location)
 }
 
 // Stop stops the cron scheduler if it is running; otherwise it does nothing.
@@ -326,6 +439,7 @@ func (c *Cron) Stop() context.Context {
 	c.runningMu.Lock()
 	defer c.runningMu.Unlock()
 	if c.running {
+		c.logger.Infof("stop")
 		c.stop <- struct{}{}
 		c.running = false
 	}
@@ -337,21 +451,23 @@ func (c *Cron) Stop() context.Context {
 	return ctx
 }
 
-// entrySnapshot returns a copy of the current cron entry list.
+// entrySnapshot returns a copy of the current cron entry list, sorted by
+// their next times. It must be called with entriesMu held.
 func (c *Cron) entrySnapshot() []Entry {
-	var entries = make([]Entry, len(c.entries))
-	for i, e := range c.entries {
+	sorted := make([]*Entry, len(c.entries))
+	copy(sorted, c.entries)
+	sort.Stable(byTime(sorted))
+	var entries = make([]Entry, len(sorted))
+	for i, e := range sorted {
 		entries[i] = *e
 	}
 	return entries
 }
 
+// removeEntry removes the entry of id, it must be called with entriesMu held.
 func (c *Cron) removeEntry(id EntryID) {
-	var entries []*Entry
-	for _, e := range c.entries {
-		if e.ID != id {
-			entries = append(entries, e)
-		}
+	if e, ok := c.index[id]; ok {
+		heap.Remove(&c.entries, e.index)
+		delete(c.index, id)
 	}
-	c.entries = entries
 }
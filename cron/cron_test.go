@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/dcron-contrib/commons/dlog"
+	"github.com/libi/dcron/clock/clocktest"
 )
 
 // Many tests schedule a job for every second, and then wait at most a second
@@ -195,6 +196,80 @@ func TestRemoveWhileRunning(t *testing.T) {
 	}
 }
 
+// Test that sub-second schedules run at their own pace.
+func TestSubSecondSchedule(t *testing.T) {
+	var calls int64
+	cron := New()
+	cron.Schedule(EveryPrecise(100*time.Millisecond), FuncJob(func() { atomic.AddInt64(&calls, 1) }))
+	cron.Start()
+	time.Sleep(550 * time.Millisecond)
+	cron.Stop()
+
+	if n := atomic.LoadInt64(&calls); n < 4 || n > 6 {
+		t.Errorf("expected about 5 calls, got %d", n)
+	}
+}
+
+func TestActivationsBetween(t *testing.T) {
+	hourly, _ := ParseStandard("@hourly")
+	never, _ := ParseStandard("0 0 30 Feb *")
+	from, to := getTime("Mon Jul 9 10:00 2012"), getTime("Mon Jul 9 13:00 2012")
+
+	times := ActivationsBetween(hourly, from, to)
+	expected := []time.Time{getTime("Mon Jul 9 10:00 2012"), getTime("Mon Jul 9 11:00 2012"), getTime("Mon Jul 9 12:00 2012")}
+	if len(times) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, times)
+	}
+	for i := range times {
+		if !times[i].Equal(expected[i]) {
+			t.Errorf("expected %v, got %v", expected[i], times[i])
+		}
+	}
+
+	if times := ActivationsBetween(never, from, to); len(times) != 0 {
+		t.Errorf("expected no activations, got %v", times)
+	}
+	if times := ActivationsBetween(Every(time.Second), from, from.AddDate(0, 0, 7)); len(times) != MaxActivations {
+		t.Errorf("expected %d activations, got %d", MaxActivations, len(times))
+	}
+}
+
+// Test the activations across a DST transition on a fake clock, without sleeping.
+func TestFakeClockDST(t *testing.T) {
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2am EST (-5) -> 3am EDT (-4), 2:30am does not exist.
+	fake := clocktest.NewFake(getTime("2012-03-11T00:45:00-0500"))
+	cron := New(WithLocation(nyc), WithClock(fake))
+	ran := make(chan struct{}, 1)
+	cron.AddFunc("30 * * * *", func() { ran <- struct{}{} })
+	cron.Start()
+	defer cron.Stop()
+
+	for _, step := range []struct {
+		advance  time.Duration
+		expected string
+	}{
+		{45 * time.Minute, "2012-03-11T01:30:00-0500"},
+		{time.Hour, "2012-03-11T03:30:00-0400"},
+		{time.Hour, "2012-03-11T04:30:00-0400"},
+	} {
+		fake.BlockUntil(1)
+		fake.Advance(step.advance)
+		select {
+		case <-ran:
+		case <-time.After(OneSecond):
+			t.Fatalf("expected the job to run at %s", step.expected)
+		}
+		fake.BlockUntil(1)
+		if prev := cron.Entries()[0].Prev; !prev.Equal(getTime(step.expected)) {
+			t.Errorf("expected the job to run at %s, ran at %v", step.expected, prev)
+		}
+	}
+}
+
 // Test timing with Entries.
 func TestSnapshotEntries(t *testing.T) {
 	wg := &sync.WaitGroup{}
@@ -0,0 +1,439 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale is the language of the schedule descriptions.
+type Locale int
+
+const (
+	// English describes the schedules in English,
+	// e.g. "At 05:00 on Monday through Friday".
+	English Locale = iota
+	// Chinese describes the schedules in Simplified Chinese,
+	// e.g. "每周一至周五 05:00".
+	Chinese
+)
+
+// Describe returns the human-readable description of the standard spec
+// (see ParseStandard), or error if the spec is not valid.
+func Describe(spec string, locale Locale) (string, error) {
+	return standardParser.Describe(spec, locale)
+}
+
+// the specs of the descriptors, with all 7 fields.
+var descriptorSpecs = map[string]string{
+	"@yearly":   "0 0 0 1 1 * *",
+	"@annually": "0 0 0 1 1 * *",
+	"@monthly":  "0 0 0 1 * * *",
+	"@weekly":   "0 0 0 * * 0 *",
+	"@daily":    "0 0 0 * * * *",
+	"@midnight": "0 0 0 * * * *",
+	"@hourly":   "0 0 * * * * *",
+}
+
+// Describe returns the human-readable description of the spec accepted by
+// the parser, or error if the spec is not valid.
+func (p Parser) Describe(spec string, locale Locale) (string, error) {
+	return p.DescribeWithKey(spec, "", locale)
+}
+
+// DescribeWithKey is Describe resolving the hashed fields of spec by key,
+// see ParseWithKey.
+func (p Parser) DescribeWithKey(spec, key string, locale Locale) (string, error) {
+	if _, err := p.ParseWithKey(spec, key); err != nil {
+		return "", err
+	}
+	d := describer{locale: locale}
+
+	var tz string
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		i := strings.Index(spec, " ")
+		eq := strings.Index(spec, "=")
+		tz, spec = spec[eq+1:i], strings.TrimSpace(spec[i:])
+	}
+
+	var text string
+	if strings.HasPrefix(spec, "@") {
+		if fields, ok := descriptorSpecs[spec]; ok {
+			text = d.fields(strings.Fields(fields))
+		} else {
+			duration, _ := time.ParseDuration(strings.TrimPrefix(spec, "@every "))
+			text = d.pick("Every "+duration.String(), "每隔"+duration.String())
+		}
+	} else {
+		fields, err := normalizeFields(strings.Fields(spec), p.options)
+		if err != nil {
+			return "", err
+		}
+		if fields, err = hashFields(fields, key); err != nil {
+			return "", err
+		}
+		text = d.fields(fields)
+	}
+
+	if tz != "" {
+		text += d.pick(" ("+tz+")", "（"+tz+"）")
+	}
+	return text, nil
+}
+
+// describer describes the fields of specs in a locale.
+type describer struct {
+	locale Locale
+}
+
+// item is an expression in the comma separated list of a field.
+type item struct {
+	start, end, step uint
+	// quartz is the Quartz-style expression, e.g. "L", "15W" or "5#3".
+	quartz string
+}
+
+// items returns the expressions of the field, or nil if the field selects
+// all values. The field must have been validated by the parser.
+func items(field string, r bounds) []item {
+	var items []item
+	for _, expr := range strings.Split(field, ",") {
+		if isQuartzExpr(expr, r) {
+			items = append(items, item{quartz: strings.ToUpper(expr)})
+			continue
+		}
+		start, end, step, star, _ := parseRange(expr, r)
+		if star {
+			return nil
+		}
+		items = append(items, item{start: start, end: end, step: step})
+	}
+	return items
+}
+
+func isQuartzExpr(expr string, r bounds) bool {
+	upper := strings.ToUpper(expr)
+	switch r.max {
+	case dom.max:
+		return strings.HasPrefix(upper, "L") || strings.HasSuffix(upper, "W")
+	case dow.max:
+		return strings.Contains(upper, "#") || (len(upper) > 1 && strings.HasSuffix(upper, "L"))
+	}
+	return false
+}
+
+func (d describer) pick(english, chinese string) string {
+	if d.locale == Chinese {
+		return chinese
+	}
+	return english
+}
+
+// join joins the phrases, e.g. "a, b and c" or "a、b和c".
+func (d describer) join(phrases []string) string {
+	if len(phrases) <= 1 {
+		return strings.Join(phrases, "")
+	}
+	last := len(phrases) - 1
+	return strings.Join(phrases[:last], d.pick(", ", "、")) + d.pick(" and ", "和") + phrases[last]
+}
+
+// unit describes the values of a field.
+type unit struct {
+	bounds bounds
+	// name returns the name of a value, e.g. "Monday" or "周一".
+	name func(v uint) string
+	// the unit of the steps, e.g. "minutes" or "分钟".
+	step string
+	// span formats the values in Chinese, e.g. "第%s分钟".
+	span string
+}
+
+// values returns the phrases of the values and ranges in items, and the
+// phrases of the steps. The Quartz-style expressions are skipped.
+func (d describer) values(items []item, u unit) (values, steps []string) {
+	for _, it := range items {
+		switch {
+		case it.quartz != "":
+		case it.start == it.end:
+			values = append(values, u.name(it.start))
+		case it.step == 1:
+			values = append(values, u.name(it.start)+d.pick(" through ", "至")+u.name(it.end))
+		default:
+			step := d.pick(fmt.Sprintf("every %d %s", it.step, u.step), fmt.Sprintf("每隔%d%s", it.step, u.step))
+			if it.start != u.bounds.min || it.end != u.bounds.max {
+				step = d.pick(
+					fmt.Sprintf("%s from %s through %s", step, u.name(it.start), u.name(it.end)),
+					u.spanOf(u.name(it.start)+"至"+u.name(it.end))+step)
+			}
+			steps = append(steps, step)
+		}
+	}
+	return values, steps
+}
+
+func (u unit) spanOf(values string) string {
+	if u.span == "" {
+		return values
+	}
+	return fmt.Sprintf(u.span, values)
+}
+
+// hasValues returns true if items has values or ranges besides the steps.
+func hasValues(items []item) bool {
+	for _, it := range items {
+		if it.quartz == "" && (it.start == it.end || it.step == 1) {
+			return true
+		}
+	}
+	return false
+}
+
+func number(v uint) string {
+	return strconv.Itoa(int(v))
+}
+
+// fields returns the description of the 7 normalized fields.
+func (d describer) fields(fields []string) string {
+	var (
+		second = items(fields[0], seconds)
+		minute = items(fields[1], minutes)
+		hour   = items(fields[2], hours)
+		days   = items(fields[3], dom)
+		month  = items(fields[4], months)
+		week   = items(fields[5], dow)
+		year   = items(fields[6], years)
+	)
+	clock := d.clock(second, minute, hour)
+	var timeOfDay string
+	if clock == "" {
+		timeOfDay = d.timeOfDay(second, minute, hour)
+	}
+	date := []string{d.days(days, week, month != nil), d.month(month), d.year(year)}
+
+	if d.locale == Chinese {
+		// from the year to the time, e.g. "2025年1月1日 09:00".
+		prefix := date[2] + date[1] + date[0]
+		switch {
+		case clock != "" && prefix == "":
+			return "每天 " + clock
+		case clock != "":
+			return prefix + " " + clock
+		case prefix == "":
+			return timeOfDay
+		default:
+			return prefix + " " + timeOfDay
+		}
+	}
+
+	// from the time to the year, e.g. "At 09:00 on day 1 of the month in January".
+	text := timeOfDay
+	if clock != "" {
+		text = "At " + clock
+	}
+	for _, part := range date {
+		if part != "" {
+			text += " " + part
+		}
+	}
+	return strings.ToUpper(text[:1]) + text[1:]
+}
+
+// clock returns the times of day if the spec activates at the fixed
+// times, e.g. "09:30 and 17:30", or "" if not.
+func (d describer) clock(second, minute, hour []item) string {
+	single := func(items []item) bool {
+		return len(items) == 1 && items[0].start == items[0].end
+	}
+	if !single(second) || !single(minute) || len(hour) == 0 {
+		return ""
+	}
+	times := make([]string, 0, len(hour))
+	for _, h := range hour {
+		if h.start != h.end {
+			return ""
+		}
+		t := fmt.Sprintf("%02d:%02d", h.start, minute[0].start)
+		if second[0].start != 0 {
+			t += fmt.Sprintf(":%02d", second[0].start)
+		}
+		times = append(times, t)
+	}
+	return d.join(times)
+}
+
+// timeOfDay returns the description of the time fields which do not
+// activate at the fixed times, e.g. "every 15 minutes, past hours 9 through 17".
+func (d describer) timeOfDay(second, minute, hour []item) string {
+	var parts []string
+	field := func(items []item, u unit, singular, plural string) {
+		values, steps := d.values(items, u)
+		if len(values) > 0 {
+			prefix := plural
+			if len(items) == 1 && items[0].start == items[0].end {
+				prefix = singular
+			}
+			if d.locale == Chinese {
+				values = []string{u.spanOf(d.join(values))}
+			} else {
+				values = []string{prefix + " " + d.join(values)}
+			}
+		}
+		parts = append(parts, d.join(append(values, steps...)))
+	}
+
+	secondUnit := unit{seconds, number, d.pick("seconds", "秒"), "第%s秒"}
+	minuteUnit := unit{minutes, number, d.pick("minutes", "分钟"), "第%s分钟"}
+	hourUnit := unit{hours, number, d.pick("hours", "小时"), "%s点"}
+	everySecond := second == nil
+	noSecond := len(second) == 1 && second[0].start == 0 && second[0].end == 0
+
+	if d.locale == Chinese {
+		// from the hour to the second, e.g. "9至17点每隔15分钟".
+		switch {
+		case hour != nil:
+			field(hour, hourUnit, "", "")
+		case hasValues(minute):
+			parts = append(parts, "每小时")
+		}
+		switch {
+		case minute != nil:
+			field(minute, minuteUnit, "", "")
+		case noSecond || hasValues(second):
+			parts = append(parts, "每分钟")
+		}
+		switch {
+		case everySecond:
+			parts = append(parts, "每秒")
+		case !noSecond:
+			field(second, secondUnit, "", "")
+		}
+		return strings.Join(parts, "")
+	}
+
+	switch {
+	case everySecond:
+		parts = append(parts, "every second")
+	case !noSecond:
+		field(second, secondUnit, "at second", "at seconds")
+	}
+	switch {
+	case minute != nil:
+		field(minute, minuteUnit, "at minute", "at minutes")
+	case noSecond:
+		parts = append(parts, "every minute")
+	}
+	if hour != nil {
+		field(hour, hourUnit, "past hour", "past hours")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// days returns the description of the day of month and day of week
+// fields, the spec activates on the days matching either of them.
+func (d describer) days(days, week []item, inMonth bool) string {
+	monthly := d.pick(" of the month", "每月")
+	if inMonth && d.locale == Chinese {
+		// "1月1日" rather than "1月每月1日".
+		monthly = ""
+	}
+
+	var phrases []string
+	if days != nil {
+		values, steps := d.values(days, unit{dom, number, d.pick("days", "天"), "%s日"})
+		var dayPhrases []string
+		if len(values) > 0 {
+			prefix := "days "
+			if len(days) == 1 && days[0].start == days[0].end {
+				prefix = "day "
+			}
+			dayPhrases = append(dayPhrases, d.pick(prefix+d.join(values), d.join(values)+"日"))
+		}
+		for _, it := range days {
+			if it.quartz != "" {
+				dayPhrases = append(dayPhrases, d.quartzDay(it.quartz))
+			}
+		}
+		dayPhrases = append(dayPhrases, steps...)
+		phrases = append(phrases, d.pick("on "+d.join(dayPhrases)+monthly, monthly+d.join(dayPhrases)))
+	}
+
+	if week != nil {
+		values, steps := d.values(week, unit{dow, d.weekday, d.pick("days", "天"), ""})
+		values = append(values, steps...)
+		if len(values) > 0 {
+			phrases = append(phrases, d.pick("on "+d.join(values), "每"+d.join(values)))
+		}
+		var nth []string
+		for _, it := range week {
+			if it.quartz != "" {
+				nth = append(nth, d.quartzWeekday(it.quartz))
+			}
+		}
+		if len(nth) > 0 {
+			phrases = append(phrases, d.pick("on "+d.join(nth)+monthly, monthly+d.join(nth)))
+		}
+	}
+	return strings.Join(phrases, d.pick(" or ", "或"))
+}
+
+// quartzDay returns the description of L, L-n, LW and nW.
+func (d describer) quartzDay(expr string) string {
+	switch {
+	case expr == "L":
+		return d.pick("the last day", "最后一天")
+	case expr == "LW":
+		return d.pick("the last weekday", "最后一个工作日")
+	case strings.HasPrefix(expr, "L-"):
+		return d.pick("the day "+expr[2:]+" days before the last day", "最后一天前"+expr[2:]+"天")
+	default:
+		day := strings.TrimSuffix(expr, "W")
+		return d.pick("the weekday nearest day "+day, "离"+day+"日最近的工作日")
+	}
+}
+
+// quartzWeekday returns the description of nL and n#k.
+func (d describer) quartzWeekday(expr string) string {
+	if i := strings.Index(expr, "#"); i >= 0 {
+		day, _ := parseIntOrName(expr[:i], dow.names)
+		nth, _ := mustParseInt(expr[i+1:])
+		ordinals := []string{"", "first", "second", "third", "fourth", "fifth"}
+		return d.pick("the "+ordinals[nth]+" "+d.weekday(day), fmt.Sprintf("第%d个%s", nth, d.weekday(day)))
+	}
+	day, _ := parseIntOrName(expr[:len(expr)-1], dow.names)
+	return d.pick("the last "+d.weekday(day), "最后一个"+d.weekday(day))
+}
+
+func (d describer) weekday(v uint) string {
+	if d.locale == Chinese {
+		return []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}[v]
+	}
+	return time.Weekday(v).String()
+}
+
+func (d describer) month(month []item) string {
+	if month == nil {
+		return ""
+	}
+	values, steps := d.values(month, unit{months, func(v uint) string {
+		return d.pick(time.Month(v).String(), number(v)+"月")
+	}, d.pick("months", "个月"), ""})
+	if len(values) > 0 {
+		values[0] = d.pick("in ", "") + values[0]
+	}
+	return d.join(append(values, steps...))
+}
+
+func (d describer) year(year []item) string {
+	if year == nil {
+		return ""
+	}
+	values, steps := d.values(year, unit{years, func(v uint) string {
+		return d.pick(number(v), number(v)+"年")
+	}, d.pick("years", "年"), ""})
+	if len(values) > 0 {
+		values[0] = d.pick("in ", "") + values[0]
+	}
+	return d.join(append(values, steps...))
+}
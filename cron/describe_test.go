@@ -0,0 +1,78 @@
+package cron
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	extended := NewParser(Second | Minute | Hour | Dom | Month | Dow | Descriptor | Extended | YearOptional)
+	tests := []struct {
+		parser           Parser
+		spec             string
+		english, chinese string
+	}{
+		{standardParser, "0 5 * * 1-5", "At 05:00 on Monday through Friday", "每周一至周五 05:00"},
+		{standardParser, "30 9,17 * * *", "At 09:30 and 17:30", "每天 09:30和17:30"},
+		{standardParser, "* * * * *", "Every minute", "每分钟"},
+		{standardParser, "*/15 * * * *", "Every 15 minutes", "每隔15分钟"},
+		{standardParser, "5/15 * * * *", "Every 15 minutes from 5 through 59", "第5至59分钟每隔15分钟"},
+		{standardParser, "30 9-17 * * *", "At minute 30, past hours 9 through 17", "9至17点第30分钟"},
+		{standardParser, "0 9-17/2 * * MON,WED,FRI", "At minute 0, every 2 hours from 9 through 17 on Monday, Wednesday and Friday", "每周一、周三和周五 9至17点每隔2小时第0分钟"},
+		{standardParser, "0 0 1-7 * *", "At 00:00 on days 1 through 7 of the month", "每月1至7日 00:00"},
+		{standardParser, "0 9 1 * MON", "At 09:00 on day 1 of the month or on Monday", "每月1日或每周一 09:00"},
+		{standardParser, "0 9 1 jan,jul ?", "At 09:00 on day 1 of the month in January and July", "1月和7月1日 09:00"},
+		{standardParser, "@daily", "At 00:00", "每天 00:00"},
+		{standardParser, "@hourly", "At minute 0", "每小时第0分钟"},
+		{standardParser, "@weekly", "At 00:00 on Sunday", "每周日 00:00"},
+		{standardParser, "@every 1h30m", "Every 1h30m0s", "每隔1h30m0s"},
+		{standardParser, "TZ=Asia/Tokyo 0 6 * * *", "At 06:00 (Asia/Tokyo)", "每天 06:00（Asia/Tokyo）"},
+		{standardParser, "CRON_TZ=UTC @every 250ms", "Every 250ms (UTC)", "每隔250ms（UTC）"},
+		{secondParser, "*/5 * * * * *", "Every 5 seconds", "每隔5秒"},
+		{secondParser, "30 * * * * *", "At second 30", "每分钟第30秒"},
+		{secondParser, "15 30 9 * * *", "At 09:30:15", "每天 09:30:15"},
+		{extended, "0 0 9 L * ?", "At 09:00 on the last day of the month", "每月最后一天 09:00"},
+		{extended, "0 0 9 LW * ?", "At 09:00 on the last weekday of the month", "每月最后一个工作日 09:00"},
+		{extended, "0 0 9 15W * ?", "At 09:00 on the weekday nearest day 15 of the month", "每月离15日最近的工作日 09:00"},
+		{extended, "0 0 9 ? * 5L", "At 09:00 on the last Friday of the month", "每月最后一个周五 09:00"},
+		{extended, "0 0 9 ? * FRI#3", "At 09:00 on the third Friday of the month", "每月第3个周五 09:00"},
+		{extended, "0 0 0 1 1 ? 2025", "At 00:00 on day 1 of the month in January in 2025", "2025年1月1日 00:00"},
+	}
+	for _, test := range tests {
+		for _, locale := range []struct {
+			locale   Locale
+			expected string
+		}{{English, test.english}, {Chinese, test.chinese}} {
+			actual, err := test.parser.Describe(test.spec, locale.locale)
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.spec, err)
+			} else if actual != locale.expected {
+				t.Errorf("%s: expected %q, got %q", test.spec, locale.expected, actual)
+			}
+		}
+	}
+}
+
+func TestDescribeErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * *", "60 * * * *", "@unknown", "TZ=Bad/Zone * * * * *"} {
+		if _, err := Describe(spec, English); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestDescribeWithKey(t *testing.T) {
+	schedule, err := standardParser.ParseWithKey("H H(0-6) * * *", "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := schedule.Next(getTime("2012-07-09T00:00:00+0000"))
+	expected, _ := Describe(fmt.Sprintf("%d %d * * *", next.Minute(), next.Hour()), English)
+	var parser DescribingParser = standardParser
+	if got, err := parser.DescribeWithKey("H H(0-6) * * *", "job", English); err != nil || got != expected {
+		t.Errorf("expected %q, got %q, %v", expected, got, err)
+	}
+	if _, err := Describe("H H(0-6) * * *", English); err == nil {
+		t.Error("expected error without key")
+	}
+}
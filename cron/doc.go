@@ -73,6 +73,14 @@ parser you saw earlier, except that its seconds field is REQUIRED:
 That emulates Quartz, the most popular alternative Cron schedule format:
 http://www.quartz-scheduler.org/documentation/quartz-2.x/tutorials/crontrigger.html
 
+A year field may be added after the day-of-week field with the Year or
+YearOptional option, for one-off and range-limited schedules. Years from 1970
+to 2099 are accepted. Once no future year matches, the schedule is not
+activated any more:
+
+	cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.YearOptional)
+	// "0 0 1 1 * 2027" runs once at the beginning of 2027
+
 Special Characters
 
 Asterisk ( * )
@@ -105,6 +113,38 @@ Question mark ( ? )
 Question mark may be used instead of '*' for leaving either day-of-month or
 day-of-week blank.
 
+Quartz-style extensions ( L W # )
+
+A parser created with the Extended option accepts the following in the
+day-of-month and day-of-week fields:
+
+	L      in day-of-month, the last day of the month
+	L-3    in day-of-month, the 3rd day before the last day of the month
+	15W    in day-of-month, the weekday nearest the 15th, within the same month
+	LW     in day-of-month, the last weekday of the month
+	5L     in day-of-week, the last Friday of the month
+	2#2    in day-of-week, the second Tuesday of the month
+
+For example:
+
+	cron.New(
+		cron.WithParser(
+			cron.NewParser(
+				cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Extended)))
+
+Hashed fields ( H )
+
+A field of H is resolved to a value derived from a stable hash of a key, e.g. the
+job name, so the jobs of the same spec spread across the field:
+
+	H           a hashed value in the field
+	H(0-6)      a hashed value in 0-6
+	H/15        every 15 from a hashed offset below 15
+
+The specs with H are parsed by ParseWithKey of KeyedScheduleParser, which Parser
+implements, and dcron passes the job name as the key. H in day-of-month selects
+from 1 to 28. For example, "H H(0-6) * * *" runs each job once a day before 7am.
+
 Predefined schedules
 
 You may use one of several pre-defined schedules in place of a cron expression.
@@ -169,6 +209,63 @@ The prefix "TZ=(TIME ZONE)" is also supported for legacy compatibility.
 Be aware that jobs scheduled during daylight-savings leap-ahead transitions will
 not be run!
 
+Descriptions
+
+Describe returns the human-readable description of a spec in English or Chinese,
+e.g. for admin UIs and logs:
+
+	cron.Describe("0 5 * * 1-5", cron.English) // At 05:00 on Monday through Friday
+	cron.Describe("0 5 * * 1-5", cron.Chinese) // 每周一至周五 05:00
+
+The specs of other parsers are described by Parser.Describe.
+
+Composed schedules
+
+Schedules may be combined into one, e.g. to run a job on either of two specs
+without adding it twice:
+
+	Union(a, b)              activates at the activations of a or b
+	Intersect(a, b)          activates at the activations of both a and b
+	Between(s, start, end)   activates at the activations of s from start until end
+	DuringHours(s, 9, 17)    activates at the activations of s from 9:00 until 17:00
+	Offset(s, -time.Minute)  activates a minute before each activation of s
+
+Walking backwards
+
+SpecSchedule and ConstantDelaySchedule implement PrevSchedule, whose Prev returns
+the previous activation time, e.g. for "when did this job last fire". The
+activations in a range, e.g. the ticks missed while a node was down, are
+enumerated by ActivationsBetween:
+
+	missed := cron.ActivationsBetween(schedule, lastRun, time.Now())
+
+Clock jumps
+
+Cron sleeps on the monotonic time, so it can detect the wall clock jumping
+while it sleeps, e.g. stepped by NTP or a paused VM resuming. A forward jump
+makes the entries miss their activations, and a backward jump makes them wait
+longer. The jumps are not detected by default, WithClockJumpPolicy enables the
+detection and decides how the entries are run, and WithClockJumpHandler
+reports each affected entry:
+
+	c := cron.New(
+		cron.WithClockJumpPolicy(cron.Skip, time.Minute),
+		cron.WithClockJumpHandler(func(j cron.ClockJump) {
+			log.Printf("entry %d missed %d runs", j.Entry, j.Missed)
+		}))
+
+Calendars
+
+A Calendar excludes times from a schedule, e.g. holidays and blackout windows.
+Wrap the schedule with ExcludingSchedule to skip the excluded activations:
+
+	holidays, _ := cron.ParseDateCalendar("2024-12-25", "2025-01-01")
+	schedule, _ := cron.ParseStandard("0 9 * * *")
+	c.Schedule(cron.ExcludingSchedule{Schedule: schedule, Calendar: holidays}, job)
+
+DateCalendar excludes whole days, WeekendCalendar excludes Saturdays and
+Sundays, and ICalCalendar excludes the events of an iCalendar (.ics) file.
+
 Job Wrappers
 
 A Cron runner may be configured with a chain of job wrappers to add
@@ -219,13 +316,16 @@ Activate it with a one-off logger as follows:
 
 Implementation
 
-Cron entries are stored in an array, sorted by their next activation time.  Cron
-sleeps until the next job is due to be run.
+Cron entries are stored in a min-heap by their next activation time, and
+indexed by their IDs.  Cron sleeps until the next job is due to be run.
 
 Upon waking:
- - it runs each entry that is active on that second
+ - it pops and runs each entry that is active on that second
  - it calculates the next run times for the jobs that were run
- - it re-sorts the array of entries by next activation time.
+ - it pushes them back to the heap by next activation time.
  - it goes to sleep until the soonest job.
+
+Adding, removing and rescheduling an entry take O(log n), and looking up an
+entry by its ID takes O(1).
 */
 package cron
@@ -0,0 +1,32 @@
+package cron
+
+// entryHeap is a min-heap of the entries by their next activation times,
+// with the zero times at the bottom. It implements heap.Interface, and each
+// entry keeps its index in the heap so it can be removed or fixed in O(log n).
+type entryHeap []*Entry
+
+func (h entryHeap) Len() int { return len(h) }
+
+func (h entryHeap) Less(i, j int) bool { return byTime(h).Less(i, j) }
+
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *entryHeap) Push(x interface{}) {
+	e := x.(*Entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil // for the garbage collector
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
@@ -0,0 +1,153 @@
+package cron
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Many entries added and removed keep the snapshot sorted and the index
+// consistent with the heap.
+func TestEntryHeap(t *testing.T) {
+	cron := New(WithLogger(DiscardLogger))
+	var ids []EntryID
+	for i := 0; i < 1000; i++ {
+		ids = append(ids, cron.Schedule(Every(time.Duration(i%97+1)*time.Second), FuncJob(func() {})))
+	}
+	for i := 0; i < len(ids); i += 3 {
+		cron.Remove(ids[i])
+	}
+	cron.Start()
+	defer cron.Stop()
+	for i := 1; i < len(ids); i += 3 {
+		cron.Remove(ids[i])
+	}
+
+	entries := cron.Entries()
+	if len(entries) != 333 {
+		t.Fatalf("expected 333 entries, got %d", len(entries))
+	}
+	if !sort.SliceIsSorted(entries, func(i, j int) bool { return entries[i].Next.Before(entries[j].Next) }) {
+		t.Error("expected the entries sorted by their next times")
+	}
+	for i, id := range ids {
+		if valid := cron.Entry(id).Valid(); valid != (i%3 == 2) {
+			t.Errorf("entry %d: expected valid %v, got %v", id, i%3 == 2, valid)
+		}
+	}
+	cron.entriesMu.Lock()
+	defer cron.entriesMu.Unlock()
+	for i, e := range cron.entries {
+		if e.index != i || cron.index[e.ID] != e {
+			t.Fatalf("entry %d: broken index %d at %d", e.ID, e.index, i)
+		}
+	}
+}
+
+// sortScheduler replicates the scheduler re-sorting all of its entries on
+// every wake and removing the entries in O(n), to benchmark against.
+type sortScheduler struct {
+	entries []*Entry
+}
+
+func (s *sortScheduler) wake(now time.Time) {
+	sort.Sort(byTime(s.entries))
+	for _, e := range s.entries {
+		if e.Next.After(now) || e.Next.IsZero() {
+			break
+		}
+		e.Prev = e.Next
+		e.Next = e.Schedule.Next(now)
+		DiscardLogger.Infof("run|now=%v, entry=%v, next=%v", now, e.ID, e.Next)
+	}
+}
+
+func (s *sortScheduler) add(e *Entry) {
+	s.entries = append(s.entries, e)
+	sort.Sort(byTime(s.entries))
+}
+
+func (s *sortScheduler) remove(id EntryID) {
+	var entries []*Entry
+	for _, e := range s.entries {
+		if e.ID != id {
+			entries = append(entries, e)
+		}
+	}
+	s.entries = entries
+}
+
+var benchmarkSizes = []int{1000, 50000}
+
+func benchmarkSchedule(i int) Schedule {
+	return Every(time.Duration(i%3600+1) * time.Second)
+}
+
+// BenchmarkWake measures a wake of the scheduler every second, running and
+// rescheduling the due entries.
+func BenchmarkWake(b *testing.B) {
+	start := getTime("Mon Jan 2 15:04 2006")
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("heap/%d", n), func(b *testing.B) {
+			cron := New(WithLogger(DiscardLogger))
+			for i := 0; i < n; i++ {
+				cron.Schedule(benchmarkSchedule(i), FuncJob(func() {}))
+			}
+			for _, e := range cron.entries {
+				e.Next = e.Schedule.Next(start)
+			}
+			heap.Init(&cron.entries)
+			cron.entriesMu.Lock()
+			defer cron.entriesMu.Unlock()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				now := start.Add(time.Duration(i+1) * time.Second)
+				cron.reschedule(cron.dueEntries(now), now)
+			}
+		})
+		b.Run(fmt.Sprintf("sort/%d", n), func(b *testing.B) {
+			s := &sortScheduler{}
+			for i := 0; i < n; i++ {
+				schedule := benchmarkSchedule(i)
+				s.entries = append(s.entries, &Entry{ID: EntryID(i + 1), Schedule: schedule, Next: schedule.Next(start)})
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.wake(start.Add(time.Duration(i+1) * time.Second))
+			}
+		})
+	}
+}
+
+// BenchmarkAddRemove measures adding an entry and removing the oldest one.
+func BenchmarkAddRemove(b *testing.B) {
+	start := getTime("Mon Jan 2 15:04 2006")
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("heap/%d", n), func(b *testing.B) {
+			cron := New(WithLogger(DiscardLogger))
+			for i := 0; i < n; i++ {
+				cron.Schedule(benchmarkSchedule(i), FuncJob(func() {}))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cron.Schedule(benchmarkSchedule(i), FuncJob(func() {}))
+				cron.Remove(EntryID(i + 1))
+			}
+		})
+		b.Run(fmt.Sprintf("sort/%d", n), func(b *testing.B) {
+			s := &sortScheduler{}
+			for i := 0; i < n; i++ {
+				schedule := benchmarkSchedule(i)
+				s.entries = append(s.entries, &Entry{ID: EntryID(i + 1), Schedule: schedule, Next: schedule.Next(start)})
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				schedule := benchmarkSchedule(i)
+				s.add(&Entry{ID: EntryID(n + i + 1), Schedule: schedule, Next: schedule.Next(start)})
+				s.remove(EntryID(i + 1))
+			}
+		})
+	}
+}
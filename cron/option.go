@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/dcron-contrib/commons/dlog"
+	"github.com/libi/dcron/clock"
 )
 
 // Option represents a modification to the default behavior of a Cron.
@@ -45,3 +46,30 @@ func WithLogger(logger dlog.Logger) Option {
 		c.logger = logger
 	}
 }
+
+// WithClock uses the provided clock instead of the system time,
+// e.g. a fake clock in tests.
+func WithClock(c clock.Clock) Option {
+	return func(cr *Cron) {
+		cr.clock = c
+	}
+}
+
+// WithClockJumpPolicy sets how the entries are run after the wall clock
+// jumps by at least threshold while Cron sleeps, e.g. stepped by NTP or
+// the system suspended. The jumps are not detected unless it is set, and a
+// zero threshold disables the detection.
+func WithClockJumpPolicy(policy ClockJumpPolicy, threshold time.Duration) Option {
+	return func(c *Cron) {
+		c.jumpPolicy = policy
+		c.jumpThreshold = threshold
+	}
+}
+
+// WithClockJumpHandler calls handler with each entry affected by a jump of
+// the wall clock, after the policy is applied.
+func WithClockJumpHandler(handler func(ClockJump)) Option {
+	return func(c *Cron) {
+		c.jumpHandler = handler
+	}
+}
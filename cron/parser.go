@@ -2,6 +2,7 @@ package cron
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math"
 	"strconv"
 	"strings"
@@ -24,6 +25,9 @@ const (
 	Dow                                    // Day of week field, default *
 	DowOptional                            // Optional day of week field, default *
 	Descriptor                             // Allow descriptors such as @monthly, @weekly, etc.
+	Extended                               // Allow Quartz-style L, W and # in Dom and Dow fields
+	Year                                   // Year field, default *
+	YearOptional                           // Optional year field, default *
 )
 
 var places = []ParseOption{
@@ -33,6 +37,7 @@ var places = []ParseOption{
 	Dom,
 	Month,
 	Dow,
+	Year,
 }
 
 var defaults = []string{
@@ -42,6 +47,7 @@ var defaults = []string{
 	"*",
 	"*",
 	"*",
+	"*",
 }
 
 // A custom Parser that can be configured.
@@ -56,18 +62,27 @@ type Parser struct {
 //
 // Examples
 //
-//  // Standard parser without descriptors
-//  specParser := NewParser(Minute | Hour | Dom | Month | Dow)
-//  sched, err := specParser.Parse("0 0 15 */3 *")
+//	// Standard parser without descriptors
+//	specParser := NewParser(Minute | Hour | Dom | Month | Dow)
+//	sched, err := specParser.Parse("0 0 15 */3 *")
+//
+//	// Same as above, just excludes time fields
+//	specParser := NewParser(Dom | Month | Dow)
+//	sched, err := specParser.Parse("15 */3 *")
 //
-//  // Same as above, just excludes time fields
-//  specParser := NewParser(Dom | Month | Dow)
-//  sched, err := specParser.Parse("15 */3 *")
+//	// Same as above, just makes Dow optional
+//	specParser := NewParser(Dom | Month | DowOptional)
+//	sched, err := specParser.Parse("15 */3")
 //
-//  // Same as above, just makes Dow optional
-//  specParser := NewParser(Dom | Month | DowOptional)
-//  sched, err := specParser.Parse("15 */3")
+//	// Standard parser with Quartz-style extensions
+//	specParser := NewParser(Minute | Hour | Dom | Month | Dow | Extended)
+//	sched, err := specParser.Parse("0 9 L * ?")   // last day of month
+//	sched, err := specParser.Parse("0 9 15W * ?") // nearest weekday to the 15th
+//	sched, err := specParser.Parse("0 9 ? * 2#2") // second Tuesday
 //
+//	// Standard parser with an optional year
+//	specParser := NewParser(Minute | Hour | Dom | Month | Dow | YearOptional)
+//	sched, err := specParser.Parse("0 0 1 1 * 2027")
 func NewParser(options ParseOption) Parser {
 	optionals := 0
 	if options&DowOptional > 0 {
@@ -76,6 +91,9 @@ func NewParser(options ParseOption) Parser {
 	if options&SecondOptional > 0 {
 		optionals++
 	}
+	if options&YearOptional > 0 {
+		optionals++
+	}
 	if optionals > 1 {
 		panic("multiple optionals may not be configured")
 	}
@@ -86,6 +104,21 @@ func NewParser(options ParseOption) Parser {
 // It returns a descriptive error if the spec is not valid.
 // It accepts crontab specs and features configured by NewParser.
 func (p Parser) Parse(spec string) (Schedule, error) {
+	return p.ParseWithKey(spec, "")
+}
+
+// ParseWithKey is Parse resolving the Jenkins-style hashed fields of spec by
+// key, e.g. the job name, so the jobs of the same spec spread across the
+// field instead of running at once:
+//
+//	H            a value in the field, e.g. "H * * * *" is hourly at a hashed minute
+//	H(a-b)       a value in [a, b], e.g. "H H(0-6) * * *" is daily before 7am
+//	H/n, H(a-b)/n every n from a hashed offset below n
+//
+// The values are stable for a key, and H in the day of month field selects
+// from 1 to 28 to run in every month. It returns an error if spec has hashed
+// fields and key is empty.
+func (p Parser) ParseWithKey(spec, key string) (Schedule, error) {
 	if len(spec) == 0 {
 		return nil, fmt.Errorf("empty spec string")
 	}
@@ -119,6 +152,10 @@ func (p Parser) Parse(spec string) (Schedule, error) {
 	if err != nil {
 		return nil, err
 	}
+	fields, err = hashFields(fields, key)
+	if err != nil {
+		return nil, err
+	}
 
 	field := func(field string, r bounds) uint64 {
 		if err != nil {
@@ -129,27 +166,41 @@ func (p Parser) Parse(spec string) (Schedule, error) {
 		return bits
 	}
 
+	schedule := &SpecSchedule{Location: loc}
+	dayField := field
+	if p.options&Extended > 0 {
+		dayField = func(field string, r bounds) uint64 {
+			if err != nil {
+				return 0
+			}
+			var bits uint64
+			bits, err = getExtendedField(field, r, schedule)
+			return bits
+		}
+	}
+
 	var (
 		second     = field(fields[0], seconds)
 		minute     = field(fields[1], minutes)
 		hour       = field(fields[2], hours)
-		dayofmonth = field(fields[3], dom)
+		dayofmonth = dayField(fields[3], dom)
 		month      = field(fields[4], months)
-		dayofweek  = field(fields[5], dow)
+		dayofweek  = dayField(fields[5], dow)
 	)
 	if err != nil {
 		return nil, err
 	}
+	if schedule.Year, err = getYearField(fields[6]); err != nil {
+		return nil, err
+	}
 
-	return &SpecSchedule{
-		Second:   second,
-		Minute:   minute,
-		Hour:     hour,
-		Dom:      dayofmonth,
-		Month:    month,
-		Dow:      dayofweek,
-		Location: loc,
-	}, nil
+	schedule.Second = second
+	schedule.Minute = minute
+	schedule.Hour = hour
+	schedule.Dom = dayofmonth
+	schedule.Month = month
+	schedule.Dow = dayofweek
+	return schedule, nil
 }
 
 // normalizeFields takes a subset set of the time fields and returns the full set
@@ -168,6 +219,10 @@ func normalizeFields(fields []string, options ParseOption) ([]string, error) {
 		options |= Dow
 		optionals++
 	}
+	if options&YearOptional > 0 {
+		options |= Year
+		optionals++
+	}
 	if optionals > 1 {
 		return nil, fmt.Errorf("multiple optionals may not be configured")
 	}
@@ -194,6 +249,8 @@ func normalizeFields(fields []string, options ParseOption) ([]string, error) {
 		switch {
 		case options&DowOptional > 0:
 			fields = append(fields, defaults[5]) // TODO: improve access to default
+		case options&YearOptional > 0:
+			fields = append(fields, defaults[6])
 		case options&SecondOptional > 0:
 			fields = append([]string{defaults[0]}, fields...)
 		default:
@@ -225,11 +282,101 @@ var standardParser = NewParser(
 //
 // It accepts
 //   - Standard crontab specs, e.g. "* * * * ?"
-//   - Descriptors, e.g. "@midnight", "@every 1h30m"
+//   - Descriptors, e.g. "@midnight", "@every 1h30m", "@every 250ms"
 func ParseStandard(standardSpec string) (Schedule, error) {
 	return standardParser.Parse(standardSpec)
 }
 
+// the bounds of the fields which may be hashed, in the order of places.
+var hashBounds = []bounds{seconds, minutes, hours, dom, months, dow}
+
+// hashFields returns the fields with the hashed expressions resolved by key.
+// Each field is hashed differently, so "H H * * *" is not at 1:01 or 2:02 only.
+func hashFields(fields []string, key string) ([]string, error) {
+	var resolved []string
+	for i, field := range fields {
+		if i >= len(hashBounds) || !strings.Contains(strings.ToUpper(field), "H") {
+			resolved = append(resolved, field)
+			continue
+		}
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		h.Write([]byte{0, byte(i)})
+		ranges := strings.FieldsFunc(field, func(r rune) bool { return r == ',' })
+		for j, expr := range ranges {
+			var err error
+			if ranges[j], err = getHashRange(expr, hashBounds[i], key, h.Sum32()); err != nil {
+				return nil, err
+			}
+		}
+		resolved = append(resolved, strings.Join(ranges, ","))
+	}
+	return resolved, nil
+}
+
+// getHashRange returns the range expression of the hashed expression:
+//
+//	"H" [ "(" number "-" number ")" ] [ "/" number ]
+//
+// or expr itself if it is not hashed.
+func getHashRange(expr string, r bounds, key string, hash uint32) (string, error) {
+	if !strings.HasPrefix(strings.ToUpper(expr), "H") {
+		return expr, nil
+	}
+	if key == "" {
+		return "", fmt.Errorf("hashed expression needs a key, use ParseWithKey: %s", expr)
+	}
+	start, end := r.min, r.max
+	if r.max == dom.max {
+		end = 28
+	}
+	rest := expr[1:]
+	if strings.HasPrefix(rest, "(") {
+		i := strings.Index(rest, ")")
+		if i < 0 {
+			return "", fmt.Errorf("missing closing parenthesis: %s", expr)
+		}
+		lowAndHigh := strings.Split(rest[1:i], "-")
+		if len(lowAndHigh) != 2 {
+			return "", fmt.Errorf("hashed range should be number-number: %s", expr)
+		}
+		var err error
+		if start, err = mustParseInt(lowAndHigh[0]); err != nil {
+			return "", err
+		}
+		if end, err = mustParseInt(lowAndHigh[1]); err != nil {
+			return "", err
+		}
+		if start < r.min || end > r.max || start > end {
+			return "", fmt.Errorf("hashed range out of range [%d, %d]: %s", r.min, r.max, expr)
+		}
+		rest = rest[i+1:]
+	}
+	step := uint(0)
+	if strings.HasPrefix(rest, "/") {
+		var err error
+		if step, err = mustParseInt(rest[1:]); err != nil {
+			return "", err
+		}
+		if step == 0 {
+			return "", fmt.Errorf("step of range should be a positive number: %s", expr)
+		}
+		rest = ""
+	}
+	if rest != "" {
+		return "", fmt.Errorf("failed to parse hashed expression: %s", expr)
+	}
+
+	span := end - start + 1
+	if step == 0 {
+		return strconv.Itoa(int(start + uint(hash)%span)), nil
+	}
+	if step < span {
+		span = step
+	}
+	return fmt.Sprintf("%d-%d/%d", start+uint(hash)%span, end, step), nil
+}
+
 // getField returns an Int with the bits set representing all of the times that
 // the field represents or error parsing field value.  A "field" is a comma-separated
 // list of "ranges".
@@ -246,27 +393,162 @@ func getField(field string, r bounds) (uint64, error) {
 	return bits, nil
 }
 
+// getExtendedField is getField of the Dom and Dow fields with the Quartz-style
+// expressions, which are set to the extended fields of s:
+//
+//	Dom: "L", "L-" number, "LW", number "W"
+//	Dow: number "L", number "#" number
+func getExtendedField(field string, r bounds, s *SpecSchedule) (uint64, error) {
+	var bits uint64
+	ranges := strings.FieldsFunc(field, func(r rune) bool { return r == ',' })
+	for _, expr := range ranges {
+		var (
+			handled bool
+			err     error
+		)
+		if r.max == dom.max {
+			handled, err = getDomExpr(expr, s)
+		} else {
+			handled, err = getDowExpr(expr, s)
+		}
+		if err != nil {
+			return bits, err
+		}
+		if handled {
+			continue
+		}
+		bit, err := getRange(expr, r)
+		if err != nil {
+			return bits, err
+		}
+		bits |= bit
+	}
+	return bits, nil
+}
+
+// getDomExpr sets the Quartz-style day of month expression to s,
+// it returns false if expr is not one of them.
+func getDomExpr(expr string, s *SpecSchedule) (bool, error) {
+	upper := strings.ToUpper(expr)
+	switch {
+	case upper == "L":
+		s.DomLast |= 1
+	case upper == "LW":
+		s.DomWeekday |= 1
+	case strings.HasPrefix(upper, "L-"):
+		offset, err := mustParseInt(expr[2:])
+		if err != nil {
+			return false, err
+		}
+		if offset < 1 || offset > dom.max-1 {
+			return false, fmt.Errorf("offset from last day (%d) out of range [1, %d]: %s", offset, dom.max-1, expr)
+		}
+		s.DomLast |= 1 << offset
+	case strings.HasSuffix(upper, "W"):
+		day, err := mustParseInt(expr[:len(expr)-1])
+		if err != nil {
+			return false, err
+		}
+		if day < dom.min || day > dom.max {
+			return false, fmt.Errorf("day of nearest weekday (%d) out of range [%d, %d]: %s", day, dom.min, dom.max, expr)
+		}
+		s.DomWeekday |= 1 << day
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// getDowExpr sets the Quartz-style day of week expression to s,
+// it returns false if expr is not one of them.
+func getDowExpr(expr string, s *SpecSchedule) (bool, error) {
+	if i := strings.Index(expr, "#"); i >= 0 {
+		day, err := parseIntOrName(expr[:i], dow.names)
+		if err != nil {
+			return false, err
+		}
+		nth, err := mustParseInt(expr[i+1:])
+		if err != nil {
+			return false, err
+		}
+		if day > dow.max {
+			return false, fmt.Errorf("day of week (%d) above maximum (%d): %s", day, dow.max, expr)
+		}
+		if nth < 1 || nth > 5 {
+			return false, fmt.Errorf("nth day of week (%d) out of range [1, 5]: %s", nth, expr)
+		}
+		s.DowNth |= 1 << (day*8 + nth)
+		return true, nil
+	}
+	if len(expr) > 1 && strings.HasSuffix(strings.ToUpper(expr), "L") {
+		day, err := parseIntOrName(expr[:len(expr)-1], dow.names)
+		if err != nil {
+			return false, err
+		}
+		if day > dow.max {
+			return false, fmt.Errorf("day of week (%d) above maximum (%d): %s", day, dow.max, expr)
+		}
+		s.DowLast |= 1 << day
+		return true, nil
+	}
+	return false, nil
+}
+
+// getYearField returns the bits of the years that the year field represents,
+// or nil if the field represents every year.
+func getYearField(field string) ([]uint64, error) {
+	bits := make([]uint64, (years.max-years.min)/64+1)
+	ranges := strings.FieldsFunc(field, func(r rune) bool { return r == ',' })
+	for _, expr := range ranges {
+		start, end, step, star, err := parseRange(expr, years)
+		if err != nil {
+			return nil, err
+		}
+		if star {
+			return nil, nil
+		}
+		for year := start; year <= end; year += step {
+			offset := year - years.min
+			bits[offset/64] |= 1 << (offset % 64)
+		}
+	}
+	return bits, nil
+}
+
 // getRange returns the bits indicated by the given expression:
-//   number | number "-" number [ "/" number ]
+//
+//	number | number "-" number [ "/" number ]
+//
 // or error parsing range.
 func getRange(expr string, r bounds) (uint64, error) {
+	start, end, step, star, err := parseRange(expr, r)
+	if err != nil {
+		return 0, err
+	}
+	var extra uint64
+	if star {
+		extra = starBit
+	}
+	return getBits(start, end, step) | extra, nil
+}
+
+// parseRange returns the range indicated by the given expression, and
+// whether the expression is a star selecting all values of the field.
+func parseRange(expr string, r bounds) (start, end, step uint, star bool, err error) {
 	var (
-		start, end, step uint
-		rangeAndStep     = strings.Split(expr, "/")
-		lowAndHigh       = strings.Split(rangeAndStep[0], "-")
-		singleDigit      = len(lowAndHigh) == 1
-		err              error
+		rangeAndStep = strings.Split(expr, "/")
+		lowAndHigh   = strings.Split(rangeAndStep[0], "-")
+		singleDigit  = len(lowAndHigh) == 1
 	)
 
-	var extra uint64
 	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
 		start = r.min
 		end = r.max
-		extra = starBit
+		star = true
 	} else {
 		start, err = parseIntOrName(lowAndHigh[0], r.names)
 		if err != nil {
-			return 0, err
+			return 0, 0, 0, false, err
 		}
 		switch len(lowAndHigh) {
 		case 1:
@@ -274,10 +556,10 @@ func getRange(expr string, r bounds) (uint64, error) {
 		case 2:
 			end, err = parseIntOrName(lowAndHigh[1], r.names)
 			if err != nil {
-				return 0, err
+				return 0, 0, 0, false, err
 			}
 		default:
-			return 0, fmt.Errorf("too many hyphens: %s", expr)
+			return 0, 0, 0, false, fmt.Errorf("too many hyphens: %s", expr)
 		}
 	}
 
@@ -287,7 +569,7 @@ func getRange(expr string, r bounds) (uint64, error) {
 	case 2:
 		step, err = mustParseInt(rangeAndStep[1])
 		if err != nil {
-			return 0, err
+			return 0, 0, 0, false, err
 		}
 
 		// Special handling: "N/step" means "N-max/step".
@@ -295,26 +577,26 @@ func getRange(expr string, r bounds) (uint64, error) {
 			end = r.max
 		}
 		if step > 1 {
-			extra = 0
+			star = false
 		}
 	default:
-		return 0, fmt.Errorf("too many slashes: %s", expr)
+		return 0, 0, 0, false, fmt.Errorf("too many slashes: %s", expr)
 	}
 
 	if start < r.min {
-		return 0, fmt.Errorf("beginning of range (%d) below minimum (%d): %s", start, r.min, expr)
+		return 0, 0, 0, false, fmt.Errorf("beginning of range (%d) below minimum (%d): %s", start, r.min, expr)
 	}
 	if end > r.max {
-		return 0, fmt.Errorf("end of range (%d) above maximum (%d): %s", end, r.max, expr)
+		return 0, 0, 0, false, fmt.Errorf("end of range (%d) above maximum (%d): %s", end, r.max, expr)
 	}
 	if start > end {
-		return 0, fmt.Errorf("beginning of range (%d) beyond end of range (%d): %s", start, end, expr)
+		return 0, 0, 0, false, fmt.Errorf("beginning of range (%d) beyond end of range (%d): %s", start, end, expr)
 	}
 	if step == 0 {
-		return 0, fmt.Errorf("step of range should be a positive number: %s", expr)
+		return 0, 0, 0, false, fmt.Errorf("step of range should be a positive number: %s", expr)
 	}
 
-	return getBits(start, end, step) | extra, nil
+	return start, end, step, star, nil
 }
 
 // parseIntOrName returns the (possibly-named) integer contained in expr.
@@ -427,6 +709,9 @@ func parseDescriptor(descriptor string, loc *time.Location) (Schedule, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse duration %s: %s", descriptor, err)
 		}
+		if duration%time.Second != 0 {
+			return EveryPrecise(duration), nil
+		}
 		return Every(duration), nil
 	}
 
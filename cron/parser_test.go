@@ -1,6 +1,7 @@
 package cron
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -137,6 +138,123 @@ func TestParseScheduleErrors(t *testing.T) {
 	}
 }
 
+var extendedParser = NewParser(Second | Minute | Hour | Dom | Month | Dow | Descriptor | Extended)
+
+func TestParseExtended(t *testing.T) {
+	entries := []struct {
+		expr     string
+		expected Schedule
+	}{
+		{"0 0 9 L * ?", &SpecSchedule{Second: 1, Hour: 1 << 9, Minute: 1, Month: all(months), Dow: all(dow),
+			DomLast: 1, Location: time.Local}},
+		{"0 0 9 L,L-3,15W,lw * ?", &SpecSchedule{Second: 1, Hour: 1 << 9, Minute: 1, Month: all(months), Dow: all(dow),
+			DomLast: 1 | 1<<3, DomWeekday: 1 | 1<<15, Location: time.Local}},
+		{"0 0 9 1,L * *", &SpecSchedule{Second: 1, Hour: 1 << 9, Minute: 1, Dom: 1 << 1, Month: all(months), Dow: all(dow),
+			DomLast: 1, Location: time.Local}},
+		{"0 0 9 ? * 2#2", &SpecSchedule{Second: 1, Hour: 1 << 9, Minute: 1, Dom: all(dom), Month: all(months),
+			DowNth: 1 << (2*8 + 2), Location: time.Local}},
+		{"0 0 9 ? * 5L,MON,fri#1", &SpecSchedule{Second: 1, Hour: 1 << 9, Minute: 1, Dom: all(dom), Month: all(months), Dow: 1 << 1,
+			DowNth: 1 << (5*8 + 1), DowLast: 1 << 5, Location: time.Local}},
+		{"0 0 9 ? * friL", &SpecSchedule{Second: 1, Hour: 1 << 9, Minute: 1, Dom: all(dom), Month: all(months),
+			DowLast: 1 << 5, Location: time.Local}},
+		{"0 0 9 1-5 * 1-5", &SpecSchedule{Second: 1, Hour: 1 << 9, Minute: 1, Dom: getBits(1, 5, 1), Month: all(months),
+			Dow: getBits(1, 5, 1), Location: time.Local}},
+	}
+	for _, c := range entries {
+		actual, err := extendedParser.Parse(c.expr)
+		if err != nil {
+			t.Errorf("%s => unexpected error %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s => expected %+v, got %+v", c.expr, c.expected, actual)
+		}
+	}
+}
+
+func TestParseExtendedErrors(t *testing.T) {
+	var tests = []struct{ expr, err string }{
+		{"0 0 0 L-0 * ?", "offset from last day (0) out of range"},
+		{"0 0 0 L-31 * ?", "offset from last day (31) out of range"},
+		{"0 0 0 L-x * ?", "failed to parse int from x"},
+		{"0 0 0 0W * ?", "day of nearest weekday (0) out of range"},
+		{"0 0 0 32W * ?", "day of nearest weekday (32) out of range"},
+		{"0 0 0 ? * 2#0", "nth day of week (0) out of range"},
+		{"0 0 0 ? * 2#6", "nth day of week (6) out of range"},
+		{"0 0 0 ? * 7#1", "day of week (7) above maximum (6)"},
+		{"0 0 0 ? * x#1", "failed to parse int from x"},
+		{"0 0 0 ? * 7L", "day of week (7) above maximum (6)"},
+		{"0 0 0 ? * L", "failed to parse int from L"},
+		{"0 0 0 ? * 1W", "failed to parse int from 1W"},
+		{"0 0 0 2#2 * ?", "failed to parse int from 2#2"},
+		{"0 L 0 * * ?", "failed to parse int from L"},
+	}
+	for _, c := range tests {
+		actual, err := extendedParser.Parse(c.expr)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s => expected %v, got %v", c.expr, c.err, err)
+		}
+		if actual != nil {
+			t.Errorf("expected nil schedule on error, got %v", actual)
+		}
+	}
+
+	// the extensions are not allowed without Extended.
+	for _, expr := range []string{"0 0 0 L * ?", "0 0 0 15W * ?", "0 0 0 ? * 2#2", "0 0 0 ? * 5L"} {
+		if _, err := secondParser.Parse(expr); err == nil {
+			t.Errorf("%s => expected error without Extended", expr)
+		}
+	}
+}
+
+var yearParser = NewParser(Minute | Hour | Dom | Month | Dow | YearOptional | Descriptor)
+
+func TestParseYear(t *testing.T) {
+	yearBits := func(years ...uint) []uint64 {
+		bits := make([]uint64, 3)
+		for _, year := range years {
+			bits[(year-1970)/64] |= 1 << ((year - 1970) % 64)
+		}
+		return bits
+	}
+	entries := []struct {
+		expr     string
+		expected []uint64
+	}{
+		{"0 0 1 1 *", nil},
+		{"0 0 1 1 * *", nil},
+		{"0 0 1 1 * ?", nil},
+		{"0 0 1 1 * 2027", yearBits(2027)},
+		{"0 0 1 1 * 1970,2099", yearBits(1970, 2099)},
+		{"0 0 1 1 * 2030-2033", yearBits(2030, 2031, 2032, 2033)},
+		{"0 0 1 1 * 2090/4", yearBits(2090, 2094, 2098)},
+		{"0 0 1 1 * */50", yearBits(1970, 2020, 2070)},
+	}
+	for _, c := range entries {
+		actual, err := yearParser.Parse(c.expr)
+		if err != nil {
+			t.Errorf("%s => unexpected error %v", c.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual.(*SpecSchedule).Year, c.expected) {
+			t.Errorf("%s => expected %b, got %b", c.expr, c.expected, actual.(*SpecSchedule).Year)
+		}
+	}
+
+	var errors = []struct{ expr, err string }{
+		{"0 0 1 1 * 1969", "below minimum (1970)"},
+		{"0 0 1 1 * 2100", "above maximum (2099)"},
+		{"0 0 1 1 * 2030-2020", "beyond end of range"},
+		{"0 0 1 1 * 20x0", "failed to parse int from 20x0"},
+		{"0 0 1 1 * 2027 *", "expected 5 to 6 fields"},
+	}
+	for _, c := range errors {
+		_, err := yearParser.Parse(c.expr)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s => expected %v, got %v", c.expr, c.err, err)
+		}
+	}
+}
+
 func TestParseSchedule(t *testing.T) {
 	tokyo, _ := time.LoadLocation("Asia/Tokyo")
 	entries := []struct {
@@ -150,6 +268,8 @@ func TestParseSchedule(t *testing.T) {
 		{standardParser, "CRON_TZ=UTC  5 * * * *", every5min(time.UTC)},
 		{secondParser, "CRON_TZ=Asia/Tokyo 0 5 * * * *", every5min(tokyo)},
 		{secondParser, "@every 5m", ConstantDelaySchedule{5 * time.Minute}},
+		{secondParser, "@every 250ms", ConstantDelaySchedule{250 * time.Millisecond}},
+		{secondParser, "@every 1.5s", ConstantDelaySchedule{1500 * time.Millisecond}},
 		{secondParser, "@midnight", midnight(time.Local)},
 		{secondParser, "TZ=UTC  @midnight", midnight(time.UTC)},
 		{secondParser, "TZ=Asia/Tokyo @midnight", midnight(tokyo)},
@@ -214,43 +334,61 @@ func TestNormalizeFields(t *testing.T) {
 			"AllFields_NoOptional",
 			[]string{"0", "5", "*", "*", "*", "*"},
 			Second | Minute | Hour | Dom | Month | Dow | Descriptor,
-			[]string{"0", "5", "*", "*", "*", "*"},
+			[]string{"0", "5", "*", "*", "*", "*", "*"},
 		},
 		{
 			"AllFields_SecondOptional_Provided",
 			[]string{"0", "5", "*", "*", "*", "*"},
 			SecondOptional | Minute | Hour | Dom | Month | Dow | Descriptor,
-			[]string{"0", "5", "*", "*", "*", "*"},
+			[]string{"0", "5", "*", "*", "*", "*", "*"},
 		},
 		{
 			"AllFields_SecondOptional_NotProvided",
 			[]string{"5", "*", "*", "*", "*"},
 			SecondOptional | Minute | Hour | Dom | Month | Dow | Descriptor,
-			[]string{"0", "5", "*", "*", "*", "*"},
+			[]string{"0", "5", "*", "*", "*", "*", "*"},
 		},
 		{
 			"SubsetFields_NoOptional",
 			[]string{"5", "15", "*"},
 			Hour | Dom | Month,
-			[]string{"0", "0", "5", "15", "*", "*"},
+			[]string{"0", "0", "5", "15", "*", "*", "*"},
 		},
 		{
 			"SubsetFields_DowOptional_Provided",
 			[]string{"5", "15", "*", "4"},
 			Hour | Dom | Month | DowOptional,
-			[]string{"0", "0", "5", "15", "*", "4"},
+			[]string{"0", "0", "5", "15", "*", "4", "*"},
 		},
 		{
 			"SubsetFields_DowOptional_NotProvided",
 			[]string{"5", "15", "*"},
 			Hour | Dom | Month | DowOptional,
-			[]string{"0", "0", "5", "15", "*", "*"},
+			[]string{"0", "0", "5", "15", "*", "*", "*"},
+		},
+		{
+			"AllFields_YearOptional_Provided",
+			[]string{"0", "0", "1", "1", "*", "2027"},
+			Minute | Hour | Dom | Month | Dow | YearOptional,
+			[]string{"0", "0", "0", "1", "1", "*", "2027"},
+		},
+		{
+			"AllFields_YearOptional_NotProvided",
+			[]string{"0", "0", "1", "1", "*"},
+			Minute | Hour | Dom | Month | Dow | YearOptional,
+			[]string{"0", "0", "0", "1", "1", "*", "*"},
+		},
+		{
+			"AllFields_Year",
+			[]string{"0", "0", "0", "1", "1", "*", "2027-2029"},
+			Second | Minute | Hour | Dom | Month | Dow | Year,
+			[]string{"0", "0", "0", "1", "1", "*", "2027-2029"},
 		},
 		{
 			"SubsetFields_SecondOptional_NotProvided",
 			[]string{"5", "15", "*"},
 			SecondOptional | Hour | Dom | Month,
-			[]string{"0", "0", "5", "15", "*", "*"},
+			[]string{"0", "0", "5", "15", "*", "*", "*"},
 		},
 	}
 
@@ -280,6 +418,12 @@ func TestNormalizeFields_Errors(t *testing.T) {
 			SecondOptional | Minute | Hour | Dom | Month | DowOptional,
 			"",
 		},
+		{
+			"SecondAndYearOptionals",
+			[]string{"0", "5", "*", "*", "*", "*"},
+			SecondOptional | Minute | Hour | Dom | Month | Dow | YearOptional,
+			"",
+		},
 		{
 			"TooManyFields",
 			[]string{"0", "5", "*", "*"},
@@ -320,7 +464,7 @@ func TestStandardSpecSchedule(t *testing.T) {
 	}{
 		{
 			expr:     "5 * * * *",
-			expected: &SpecSchedule{1 << seconds.min, 1 << 5, all(hours), all(dom), all(months), all(dow), time.Local},
+			expected: &SpecSchedule{Second: 1 << seconds.min, Minute: 1 << 5, Hour: all(hours), Dom: all(dom), Month: all(months), Dow: all(dow), Location: time.Local},
 		},
 		{
 			expr:     "@every 5m",
@@ -359,15 +503,15 @@ func TestNoDescriptorParser(t *testing.T) {
 }
 
 func every5min(loc *time.Location) *SpecSchedule {
-	return &SpecSchedule{1 << 0, 1 << 5, all(hours), all(dom), all(months), all(dow), loc}
+	return &SpecSchedule{Second: 1 << 0, Minute: 1 << 5, Hour: all(hours), Dom: all(dom), Month: all(months), Dow: all(dow), Location: loc}
 }
 
 func every5min5s(loc *time.Location) *SpecSchedule {
-	return &SpecSchedule{1 << 5, 1 << 5, all(hours), all(dom), all(months), all(dow), loc}
+	return &SpecSchedule{Second: 1 << 5, Minute: 1 << 5, Hour: all(hours), Dom: all(dom), Month: all(months), Dow: all(dow), Location: loc}
 }
 
 func midnight(loc *time.Location) *SpecSchedule {
-	return &SpecSchedule{1, 1, 1, all(dom), all(months), all(dow), loc}
+	return &SpecSchedule{Second: 1, Minute: 1, Hour: 1, Dom: all(dom), Month: all(months), Dow: all(dow), Location: loc}
 }
 
 func annual(loc *time.Location) *SpecSchedule {
@@ -381,3 +525,90 @@ func annual(loc *time.Location) *SpecSchedule {
 		Location: loc,
 	}
 }
+
+func TestParseHash(t *testing.T) {
+	parse := func(spec, key string) *SpecSchedule {
+		s, err := standardParser.ParseWithKey(spec, key)
+		if err != nil {
+			t.Fatalf("%s => unexpected error %v", spec, err)
+		}
+		return s.(*SpecSchedule)
+	}
+	values := func(bits uint64, r bounds) []uint {
+		var values []uint
+		for i := r.min; i <= r.max; i++ {
+			if bits&(1<<i) > 0 {
+				values = append(values, i)
+			}
+		}
+		return values
+	}
+
+	minutesOf := map[uint]bool{}
+	for i := 0; i < 60; i++ {
+		key := fmt.Sprintf("job-%d", i)
+		s := parse("H H(0-6) H * *", key)
+		if !reflect.DeepEqual(s, parse("H H(0-6) H * *", key)) {
+			t.Fatalf("%s => expected the same schedule for the same key", key)
+		}
+		minute, hour, day := values(s.Minute, minutes), values(s.Hour, hours), values(s.Dom, dom)
+		if len(minute) != 1 || len(hour) != 1 || hour[0] > 6 || len(day) != 1 || day[0] > 28 {
+			t.Errorf("%s => unexpected minute %v, hour %v, day %v", key, minute, hour, day)
+		}
+		minutesOf[minute[0]] = true
+	}
+	if len(minutesOf) < 20 {
+		t.Errorf("expected the minutes spread by the keys, got %d minutes", len(minutesOf))
+	}
+
+	for _, c := range []struct {
+		spec     string
+		count    int
+		min, max uint
+		step     uint
+	}{
+		{"H/15 * * * *", 4, 0, 14, 15},
+		{"H(10-29)/5 * * * *", 4, 10, 14, 5},
+		{"H(10-12)/5 * * * *", 1, 10, 12, 5},
+	} {
+		minute := values(parse(c.spec, "job").Minute, minutes)
+		if len(minute) != c.count || minute[0] < c.min || minute[0] > c.max {
+			t.Errorf("%s => unexpected minutes %v", c.spec, minute)
+		}
+		for i := 1; i < len(minute); i++ {
+			if minute[i]-minute[i-1] != c.step {
+				t.Errorf("%s => unexpected minutes %v", c.spec, minute)
+			}
+		}
+	}
+
+	// the fields are hashed differently.
+	s := parse("H H * * *", "job")
+	if reflect.DeepEqual(values(s.Minute, minutes), values(s.Hour, hours)) {
+		t.Errorf("expected the minute and the hour hashed differently, got %+v", s)
+	}
+}
+
+func TestParseHashErrors(t *testing.T) {
+	var tests = []struct{ expr, err string }{
+		{"H(5-1) * * * *", "hashed range out of range"},
+		{"H(0-60) * * * *", "hashed range out of range"},
+		{"H(1) * * * *", "hashed range should be number-number"},
+		{"H(1-2 * * * *", "missing closing parenthesis"},
+		{"H/0 * * * *", "step of range should be a positive number"},
+		{"Hx * * * *", "failed to parse hashed expression"},
+	}
+	for _, c := range tests {
+		actual, err := standardParser.ParseWithKey(c.expr, "job")
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s => expected %v, got %v", c.expr, c.err, err)
+		}
+		if actual != nil {
+			t.Errorf("expected nil schedule on error, got %v", actual)
+		}
+	}
+
+	if _, err := standardParser.Parse("H * * * *"); err == nil || !strings.Contains(err.Error(), "needs a key") {
+		t.Errorf("expected an error without a key, got %v", err)
+	}
+}
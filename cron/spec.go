@@ -9,6 +9,20 @@ type SpecSchedule struct {
 
 	// Override location for this schedule.
 	Location *time.Location
+
+	// The Quartz-style days, which are set only by a parser with Extended.
+	// They are matched like the days in Dom and Dow.
+	//
+	// DomLast has bit n set for the n-th day before the last day of month (L, L-n).
+	// DomWeekday has bit n set for the weekday nearest the n-th day (nW),
+	// and bit 0 set for the last weekday of month (LW).
+	// DowNth has bit d*8+n set for the n-th day d of week in month (d#n).
+	// DowLast has bit d set for the last day d of week in month (dL).
+	DomLast, DomWeekday, DowNth, DowLast uint64
+
+	// Year has bit y-1970 set for each year y the schedule is activated in,
+	// nil means every year. It is set only by a parser with Year or YearOptional.
+	Year []uint64
 }
 
 // bounds provides a range of acceptable values (plus a map of name to value).
@@ -23,6 +37,7 @@ var (
 	minutes = bounds{0, 59, nil}
 	hours   = bounds{0, 23, nil}
 	dom     = bounds{1, 31, nil}
+	years   = bounds{1970, 2099, nil}
 	months  = bounds{1, 12, map[string]uint{
 		"jan": 1,
 		"feb": 2,
@@ -88,6 +103,18 @@ func (s *SpecSchedule) Next(t time.Time) time.Time {
 	yearLimit := t.Year() + 5
 
 WRAP:
+	// Find the first applicable year.
+	if !yearMatches(s.Year, t.Year()) {
+		year := nextYear(s.Year, t.Year())
+		if year == 0 {
+			// No year in the future matches.
+			return time.Time{}
+		}
+		added = true
+		t = time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+		yearLimit = year + 5
+	}
+
 	if t.Year() > yearLimit {
 		return time.Time{}
 	}
@@ -174,15 +201,206 @@ WRAP:
 	return t.In(origLocation)
 }
 
+// Prev returns the previous time this schedule is activated, less than the given
+// time.  If no time can be found to satisfy the schedule, return the zero time.
+func (s *SpecSchedule) Prev(t time.Time) time.Time {
+	// General approach
+	//
+	// The same as Next, but each field that doesn't match is decremented to the
+	// last second of its previous value, e.g. the month to the last second of the
+	// previous month. A wrap-around brings it back to the beginning of the field
+	// list.
+
+	origLocation := t.Location()
+	loc := s.Location
+	if loc == time.Local {
+		loc = t.Location()
+	}
+	if s.Location != time.Local {
+		t = t.In(s.Location)
+	}
+
+	// Start at the latest possible time (the previous second).
+	if t.Nanosecond() > 0 {
+		t = t.Add(-time.Duration(t.Nanosecond()) * time.Nanosecond)
+	} else {
+		t = t.Add(-1 * time.Second)
+	}
+
+	// If no time is found within five years, return zero.
+	yearLimit := t.Year() - 5
+
+WRAP:
+	// Find the last applicable year.
+	if !yearMatches(s.Year, t.Year()) {
+		year := prevYear(s.Year, t.Year())
+		if year == 0 {
+			// No year in the past matches.
+			return time.Time{}
+		}
+		t = time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc).Add(-1 * time.Second)
+		yearLimit = year - 5
+	}
+
+	if t.Year() < yearLimit {
+		return time.Time{}
+	}
+
+	for 1<<uint(t.Month())&s.Month == 0 {
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc).Add(-1 * time.Second)
+
+		// Wrapped around.
+		if t.Month() == time.December {
+			goto WRAP
+		}
+	}
+
+	for !dayMatches(s, t) {
+		month := t.Month()
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).Add(-1 * time.Second)
+
+		if t.Month() != month {
+			goto WRAP
+		}
+	}
+
+	// The hours and minutes are decremented by the elapsed time rather than
+	// time.Date, which is ambiguous when the clocks are turned back.
+	for 1<<uint(t.Hour())&s.Hour == 0 {
+		day := t.Day()
+		t = t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second()+1)*time.Second)
+
+		if t.Day() != day {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Minute())&s.Minute == 0 {
+		hour := t.Hour()
+		t = t.Add(-time.Duration(t.Second()+1) * time.Second)
+
+		if t.Hour() != hour {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Second())&s.Second == 0 {
+		t = t.Add(-1 * time.Second)
+
+		if t.Second() == 59 {
+			goto WRAP
+		}
+	}
+
+	return t.In(origLocation)
+}
+
+// yearMatches returns true if the year is one of the years.
+func yearMatches(bits []uint64, year int) bool {
+	if bits == nil {
+		return true
+	}
+	if year < int(years.min) || year > int(years.max) {
+		return false
+	}
+	offset := uint(year) - years.min
+	return bits[offset/64]&(1<<(offset%64)) > 0
+}
+
+// nextYear returns the first of the years after the year, or 0 if none.
+func nextYear(bits []uint64, year int) int {
+	if year < int(years.min) {
+		year = int(years.min) - 1
+	}
+	for y := year + 1; y <= int(years.max); y++ {
+		if yearMatches(bits, y) {
+			return y
+		}
+	}
+	return 0
+}
+
+// prevYear returns the last of the years before the year, or 0 if none.
+func prevYear(bits []uint64, year int) int {
+	if year > int(years.max) {
+		year = int(years.max) + 1
+	}
+	for y := year - 1; y >= int(years.min); y-- {
+		if yearMatches(bits, y) {
+			return y
+		}
+	}
+	return 0
+}
+
 // dayMatches returns true if the schedule's day-of-week and day-of-month
 // restrictions are satisfied by the given time.
 func dayMatches(s *SpecSchedule, t time.Time) bool {
 	var (
-		domMatch bool = 1<<uint(t.Day())&s.Dom > 0
-		dowMatch bool = 1<<uint(t.Weekday())&s.Dow > 0
+		domMatch bool = 1<<uint(t.Day())&s.Dom > 0 || extendedDomMatches(s, t)
+		dowMatch bool = 1<<uint(t.Weekday())&s.Dow > 0 || extendedDowMatches(s, t)
 	)
 	if s.Dom&starBit > 0 || s.Dow&starBit > 0 {
 		return domMatch && dowMatch
 	}
 	return domMatch || dowMatch
 }
+
+// extendedDomMatches returns true if the given time satisfies
+// the Quartz-style day-of-month restrictions of the schedule.
+func extendedDomMatches(s *SpecSchedule, t time.Time) bool {
+	if s.DomLast == 0 && s.DomWeekday == 0 {
+		return false
+	}
+	last := daysIn(t.Month(), t.Year())
+	if 1<<uint(last-t.Day())&s.DomLast > 0 {
+		return true
+	}
+	if s.DomWeekday&1 > 0 && t.Day() == nearestWeekday(t, last) {
+		return true
+	}
+	for day := 1; day <= last; day++ {
+		if 1<<uint(day)&s.DomWeekday > 0 && t.Day() == nearestWeekday(t, day) {
+			return true
+		}
+	}
+	return false
+}
+
+// extendedDowMatches returns true if the given time satisfies
+// the Quartz-style day-of-week restrictions of the schedule.
+func extendedDowMatches(s *SpecSchedule, t time.Time) bool {
+	if s.DowNth == 0 && s.DowLast == 0 {
+		return false
+	}
+	weekday := uint(t.Weekday())
+	nth := uint(t.Day()-1)/7 + 1
+	if 1<<(weekday*8+nth)&s.DowNth > 0 {
+		return true
+	}
+	return 1<<weekday&s.DowLast > 0 && t.Day()+7 > daysIn(t.Month(), t.Year())
+}
+
+// nearestWeekday returns the weekday nearest the day in the month of t,
+// without crossing the month.
+func nearestWeekday(t time.Time, day int) int {
+	last := daysIn(t.Month(), t.Year())
+	switch time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+// daysIn returns the number of days in the month of the year.
+func daysIn(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
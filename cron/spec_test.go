@@ -199,6 +199,187 @@ func TestNext(t *testing.T) {
 	}
 }
 
+func TestNextExtended(t *testing.T) {
+	runs := []struct {
+		time, spec string
+		expected   string
+	}{
+		// Last day of month
+		{"Mon Jul 9 14:45 2012", "0 0 9 L * ?", "Tue Jul 31 09:00 2012"},
+		{"Tue Jul 31 10:00 2012", "0 0 9 L * ?", "Fri Aug 31 09:00 2012"},
+		{"Sun Jan 1 00:00 2012", "0 0 0 L 2 ?", "Wed Feb 29 00:00 2012"},
+		{"Thu Mar 1 00:00 2012", "0 0 0 L 2 ?", "Thu Feb 28 00:00 2013"},
+		{"Sun Jul 1 00:00 2012", "0 0 0 L-2 * ?", "Sun Jul 29 00:00 2012"},
+		{"Mon Jul 2 00:00 2012", "0 0 0 1,L * ?", "Tue Jul 31 00:00 2012"},
+
+		// Nearest weekday
+		{"Sun Jul 1 00:00 2012", "0 0 0 15W * ?", "Mon Jul 16 00:00 2012"},
+		{"Sat Sep 1 00:00 2012", "0 0 0 15W * ?", "Fri Sep 14 00:00 2012"},
+		{"Wed Feb 1 00:00 2012", "0 0 0 15W * ?", "Wed Feb 15 00:00 2012"},
+		{"Fri Aug 31 12:00 2012", "0 0 0 1W * ?", "Mon Sep 3 00:00 2012"},
+		{"Sun Sep 16 00:00 2012", "0 0 0 30W * ?", "Fri Sep 28 00:00 2012"},
+		{"Sat Sep 1 00:00 2012", "0 0 0 31W * ?", "Wed Oct 31 00:00 2012"},
+
+		// Last weekday of month
+		{"Fri Jun 1 00:00 2012", "0 0 0 LW * ?", "Fri Jun 29 00:00 2012"},
+		{"Fri Mar 1 00:00 2013", "0 0 0 LW * ?", "Fri Mar 29 00:00 2013"},
+		{"Sun Jul 1 00:00 2012", "0 0 0 LW * ?", "Tue Jul 31 00:00 2012"},
+
+		// Nth day of week
+		{"Sun Jul 1 00:00 2012", "0 0 0 ? * 2#2", "Tue Jul 10 00:00 2012"},
+		{"Wed Jul 11 00:00 2012", "0 0 0 ? * 2#2", "Tue Aug 14 00:00 2012"},
+		{"Sun Jul 1 00:00 2012", "0 0 0 ? * FRI#1", "Fri Jul 6 00:00 2012"},
+		{"Sun Jul 1 00:00 2012", "0 0 0 ? * 2#5", "Tue Jul 31 00:00 2012"},
+		{"Wed Aug 1 00:00 2012", "0 0 0 ? * 2#5", "Tue Oct 30 00:00 2012"},
+
+		// Last day of week in month
+		{"Sun Jul 1 00:00 2012", "0 0 0 ? * 5L", "Fri Jul 27 00:00 2012"},
+		{"Fri Feb 1 00:00 2013", "0 0 0 ? * friL", "Fri Feb 22 00:00 2013"},
+
+		// Restricted months
+		{"Sun Jul 1 00:00 2012", "0 0 0 ? 12 2#1", "Tue Dec 4 00:00 2012"},
+
+		// Both fields are restricted, either matches
+		{"Tue Jul 24 00:00 2012", "0 0 0 L * MON", "Mon Jul 30 00:00 2012"},
+		{"Tue Jul 24 00:00 2012", "0 0 0 L * 2#1", "Tue Jul 31 00:00 2012"},
+
+		// Unspecified both
+		{"Tue Jul 24 00:00 2012", "0 0 0 ? * ?", "Wed Jul 25 00:00 2012"},
+	}
+
+	for _, c := range runs {
+		sched, err := extendedParser.Parse(c.spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		actual := sched.Next(getTime(c.time))
+		expected := getTime(c.expected)
+		if !actual.Equal(expected) {
+			t.Errorf("%s, \"%s\": (expected) %v != %v (actual)", c.time, c.spec, expected, actual)
+		}
+	}
+}
+
+func TestNextYear(t *testing.T) {
+	runs := []struct {
+		time, spec string
+		expected   string
+	}{
+		// One-off schedules
+		{"Mon Jul 9 14:45 2012", "0 0 1 1 * 2027", "Fri Jan 1 00:00 2027"},
+		{"Fri Jan 1 00:00 2027", "0 0 1 1 * 2027", ""},
+		{"Mon Jul 9 14:45 2012", "0 0 1 1 * 2099", "Thu Jan 1 00:00 2099"},
+
+		// Range-limited schedules
+		{"Thu Mar 1 00:00 2012", "0 0 29 2 * 2012-2030", "Mon Feb 29 00:00 2016"},
+		{"Mon Jul 9 14:45 2012", "0 0 29 2 * 2013,2014,2015", ""},
+		{"Mon Jul 9 14:45 2012", "0 0 1 1 * */10", "Wed Jan 1 00:00 2020"},
+		{"Mon Dec 31 11:00 2012", "0 12 * * * 2012", "Mon Dec 31 12:00 2012"},
+		{"Mon Dec 31 13:00 2012", "0 12 * * * 2012", ""},
+
+		// Year omitted
+		{"Mon Dec 31 13:00 2012", "0 12 * * *", "Tue Jan 1 12:00 2013"},
+	}
+
+	for _, c := range runs {
+		sched, err := yearParser.Parse(c.spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		actual := sched.Next(getTime(c.time))
+		expected := getTime(c.expected)
+		if !actual.Equal(expected) {
+			t.Errorf("%s, \"%s\": (expected) %v != %v (actual)", c.time, c.spec, expected, actual)
+		}
+	}
+}
+
+func TestPrev(t *testing.T) {
+	runs := []struct {
+		time, spec string
+		expected   string
+	}{
+		// Simple cases
+		{"Mon Jul 9 14:45 2012", "0 0/15 * * * *", "Mon Jul 9 14:30 2012"},
+		{"Mon Jul 9 14:44:59 2012", "0 0/15 * * * *", "Mon Jul 9 14:30 2012"},
+		{"Mon Jul 9 14:45:00.5 2012", "0 0/15 * * * *", "Mon Jul 9 14:45 2012"},
+
+		// Wrap around hours, days, months and years
+		{"Mon Jul 9 14:45 2012", "0 50 15 * * *", "Sun Jul 8 15:50 2012"},
+		{"Mon Jul 9 00:00 2012", "0 30 23 * * *", "Sun Jul 8 23:30 2012"},
+		{"Sun Jul 1 00:00 2012", "0 0 12 31 * *", "Sun May 31 12:00 2012"},
+		{"Sun Jan 1 00:00 2012", "0 0 0 * Dec *", "Sat Dec 31 00:00 2011"},
+
+		// Leap year
+		{"Mon Jul 9 23:35 2012", "0 0 0 29 Feb ?", "Wed Feb 29 00:00 2012"},
+
+		// Daylight savings time 2am EST (-5) -> 3am EDT (-4)
+		{"2012-03-11T03:00:00-0400", "TZ=America/New_York 0 30 2 11 Mar ?", "2011-03-11T02:30:00-0500"},
+		{"2012-03-11T04:00:00-0400", "TZ=America/New_York 0 30 * * * ?", "2012-03-11T03:30:00-0400"},
+		{"2012-03-11T03:30:00-0400", "TZ=America/New_York 0 30 * * * ?", "2012-03-11T01:30:00-0500"},
+
+		// Daylight savings time 2am EDT (-4) => 1am EST (-5)
+		{"2012-11-04T02:00:00-0500", "TZ=America/New_York 0 30 * * * ?", "2012-11-04T01:30:00-0500"},
+		{"2012-11-04T01:30:00-0500", "TZ=America/New_York 0 30 * * * ?", "2012-11-04T01:30:00-0400"},
+
+		// Unsatisfiable
+		{"Mon Jul 9 23:35 2012", "0 0 0 30 Feb ?", ""},
+		{"Mon Jul 9 23:35 2012", "0 0 0 31 Apr ?", ""},
+	}
+
+	for _, c := range runs {
+		sched, err := secondParser.Parse(c.spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		actual := sched.(PrevSchedule).Prev(getTime(c.time))
+		expected := getTime(c.expected)
+		if !actual.Equal(expected) {
+			t.Errorf("%s, \"%s\": (expected) %v != %v (actual)", c.time, c.spec, expected, actual)
+		}
+	}
+}
+
+// TestPrevNext checks that Prev finds the activations found by Next,
+// walking backwards from the times found by Next.
+func TestPrevNext(t *testing.T) {
+	runs := []struct {
+		parser Parser
+		spec   string
+	}{
+		{secondParser, "0 0/15 * * * *"},
+		{secondParser, "30 5 9-17 * * MON-FRI"},
+		{secondParser, "0 0 0 1,15 * ?"},
+		{secondParser, "0 0 12 1 * MON"},
+		{secondParser, "@weekly"},
+		{secondParser, "TZ=America/New_York 0 30 1 * * ?"},
+		{secondParser, "TZ=Asia/Kolkata 0 0 0 * * ?"},
+		{extendedParser, "0 0 9 L * ?"},
+		{extendedParser, "0 0 9 15W * ?"},
+		{extendedParser, "0 0 9 ? * 5#3"},
+		{yearParser, "0 0 1 * * 2012,2014"},
+	}
+	for _, c := range runs {
+		sched, err := c.parser.Parse(c.spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var times []time.Time
+		for next := sched.Next(getTime("Sun Jan 1 00:00 2012")); !next.IsZero() && len(times) < 50; next = sched.Next(next) {
+			times = append(times, next)
+		}
+		for i := len(times) - 1; i > 0; i-- {
+			if prev := sched.(PrevSchedule).Prev(times[i]); !prev.Equal(times[i-1]) {
+				t.Errorf("%s: Prev(%v) (expected) %v != %v (actual)", c.spec, times[i], times[i-1], prev)
+			}
+		}
+	}
+}
+
 func TestErrors(t *testing.T) {
 	invalidSpecs := []string{
 		"xyz",
@@ -11,6 +11,7 @@ import (
 
 	"github.com/dcron-contrib/commons"
 	"github.com/dcron-contrib/commons/dlog"
+	"github.com/libi/dcron/clock"
 	"github.com/libi/dcron/cron"
 )
 
@@ -29,10 +30,30 @@ var (
 	ErrJobExist     = errors.New("jobName already exist")
 	ErrJobNotExist  = errors.New("jobName not exist")
 	ErrJobWrongNode = errors.New("job is not running in this node")
+	ErrJobConflict  = errors.New("job options conflict")
+
+	ErrDcronNotRunning = errors.New("dcron is not running")
 )
 
 type RecoverFuncType func(d *Dcron)
 
+// Assignments is the owner nodes of the jobs in dcron.
+type Assignments struct {
+	RingVersion uint64
+	UpdatedAt   time.Time
+	// Owners maps jobName to the owner nodeID.
+	Owners map[string]string
+}
+
+// RingNodes is the nodes in the hash ring of dcron.
+type RingNodes struct {
+	RingVersion uint64
+	UpdatedAt   time.Time
+	Nodes       []string
+	// Zones maps nodeID to its zone, the nodes without zone are left out.
+	Zones map[string]string
+}
+
 // Dcron is main struct
 type Dcron struct {
 	jobs      map[string]*JobWarpper
@@ -50,10 +71,20 @@ type Dcron struct {
 	cr        *cron.Cron
 	crOptions []cron.Option
 
+	nodePoolOptions []NodePoolOption
+
+	calendars []cron.Calendar
+
+	clock clock.Clock
+
 	RecoverFunc RecoverFuncType
 
-	recentJobs IRecentJobPacker
-	state      atomic.Value
+	recentJobs       IRecentJobPacker
+	recentJobsWindow time.Duration
+	state            atomic.Value
+
+	ready     chan struct{}
+	readyOnce sync.Once
 
 	runningLocally bool
 }
@@ -76,8 +107,11 @@ func NewDcronWithOption(serverName string, driver commons.DriverV2, dcronOpts ..
 	}
 
 	dcron.cr = cron.New(dcron.crOptions...)
+	if dcron.recentJobsWindow > 0 {
+		dcron.recentJobs = NewRecentJobPackerWithClock(dcron.recentJobsWindow, dcron.clock)
+	}
 	if !dcron.runningLocally {
-		dcron.nodePool = NewNodePool(serverName, driver, dcron.nodeUpdateDuration, dcron.hashReplicas, dcron.logger)
+		dcron.nodePool = NewNodePool(serverName, driver, dcron.nodeUpdateDuration, dcron.hashReplicas, dcron.logger, dcron.nodePoolOptions...)
 	}
 	return dcron
 }
@@ -90,8 +124,11 @@ func newDcron(serverName string) *Dcron {
 		},
 		jobs:               make(map[string]*JobWarpper),
 		crOptions:          make([]cron.Option, 0),
+		nodePoolOptions:    make([]NodePoolOption, 0),
 		nodeUpdateDuration: defaultDuration,
 		hashReplicas:       defaultReplicas,
+		clock:              clock.Real(),
+		ready:              make(chan struct{}),
 	}
 }
 
@@ -106,17 +143,43 @@ func (d *Dcron) GetLogger() dlog.Logger {
 }
 
 // AddJob  add a job
-func (d *Dcron) AddJob(jobName, cronStr string, job Job) (err error) {
-	return d.addJob(jobName, cronStr, job)
+func (d *Dcron) AddJob(jobName, cronStr string, job Job, opts ...JobOption) (err error) {
+	return d.addJob(jobName, cronStr, job, opts...)
 }
 
 // AddFunc add a cron func
-func (d *Dcron) AddFunc(jobName, cronStr string, cmd func()) (err error) {
-	return d.addJob(jobName, cronStr, cron.FuncJob(cmd))
+func (d *Dcron) AddFunc(jobName, cronStr string, cmd func(), opts ...JobOption) (err error) {
+	return d.addJob(jobName, cronStr, cron.FuncJob(cmd), opts...)
 }
-func (d *Dcron) addJob(jobName, cronStr string, job Job) (err error) {
+
+// AddContextFunc add a cron func with the context of each execution
+func (d *Dcron) AddContextFunc(jobName, cronStr string, cmd func(ctx context.Context), opts ...JobOption) (err error) {
+	return d.addJob(jobName, cronStr, ContextFuncJob(cmd), opts...)
+}
+
+// AddScheduleJob add a job activated by schedule, e.g. the composed
+// schedules like cron.Union(a, b).
+func (d *Dcron) AddScheduleJob(jobName string, schedule cron.Schedule, job Job, opts ...JobOption) (err error) {
+	d.logger.Infof("addJob '%s' : %T", jobName, schedule)
+	return d.addSchedule(jobName, "", schedule, job, opts...)
+}
+
+func (d *Dcron) addJob(jobName, cronStr string, job Job, opts ...JobOption) (err error) {
 	d.logger.Infof("addJob '%s' : %s", jobName, cronStr)
+	var schedule cron.Schedule
+	// the hashed fields of the spec are resolved by the job name.
+	if parser, ok := d.cr.Parser().(cron.KeyedScheduleParser); ok {
+		schedule, err = parser.ParseWithKey(cronStr, jobName)
+	} else {
+		schedule, err = d.cr.Parser().Parse(cronStr)
+	}
+	if err != nil {
+		return err
+	}
+	return d.addSchedule(jobName, cronStr, schedule, job, opts...)
+}
 
+func (d *Dcron) addSchedule(jobName, cronStr string, schedule cron.Schedule, job Job, opts ...JobOption) (err error) {
 	d.jobsRWMut.Lock()
 	defer d.jobsRWMut.Unlock()
 	if _, ok := d.jobs[jobName]; ok {
@@ -128,10 +191,19 @@ func (d *Dcron) addJob(jobName, cronStr string, job Job) (err error) {
 		Job:     job,
 		Dcron:   d,
 	}
-	entryID, err := d.cr.AddJob(cronStr, innerJob)
-	if err != nil {
+	for _, opt := range opts {
+		opt(innerJob)
+	}
+	if err = innerJob.checkOptions(); err != nil {
 		return err
 	}
+	innerJob.tickPrecision = tickPrecision(schedule)
+	calendars := append(append([]cron.Calendar{}, innerJob.Calendars...), d.calendars...)
+	for _, calendar := range calendars {
+		schedule = cron.ExcludingSchedule{Schedule: schedule, Calendar: calendar}
+	}
+	innerJob.schedule = schedule
+	entryID := d.cr.Schedule(schedule, innerJob)
 	innerJob.ID = entryID
 	d.jobs[jobName] = innerJob
 	return nil
@@ -166,7 +238,7 @@ func (d *Dcron) GetJob(jobName string, thisNodeOnly bool) (*JobWarpper, error) {
 	if !thisNodeOnly {
 		return job, nil
 	}
-	isRunningHere, err := d.nodePool.CheckJobAvailable(jobName)
+	isRunningHere, err := d.checkJobAvailable(*job)
 	if err != nil {
 		return nil, err
 	}
@@ -196,7 +268,7 @@ func (d *Dcron) GetJobs(thisNodeOnly bool) []*JobWarpper {
 			err           error
 		)
 		if thisNodeOnly {
-			isRunningHere, err = d.nodePool.CheckJobAvailable(v.Name)
+			isRunningHere, err = d.checkJobAvailable(*v)
 			if err != nil {
 				continue
 			}
@@ -209,11 +281,61 @@ func (d *Dcron) GetJobs(thisNodeOnly bool) []*JobWarpper {
 	return ret
 }
 
-func (d *Dcron) allowThisNodeRun(jobName string) (ok bool) {
+// Assignments returns the owner nodes of all jobs added to dcron,
+// which are resolved by the hash ring of this node.
+func (d *Dcron) Assignments() (Assignments, error) {
+	if d.runningLocally {
+		return Assignments{}, ErrNodePoolIsNil
+	}
+	snapshot, err := d.nodePool.GetRingSnapshot()
+	if err != nil {
+		return Assignments{}, err
+	}
+	d.jobsRWMut.RLock()
+	defer d.jobsRWMut.RUnlock()
+	owners := make(map[string]string, len(d.jobs))
+	for jobName, job := range d.jobs {
+		owners[jobName] = snapshot.OwnerInZone(jobName, job.HomeZone)
+	}
+	return Assignments{
+		RingVersion: snapshot.Version,
+		UpdatedAt:   snapshot.UpdatedAt,
+		Owners:      owners,
+	}, nil
+}
+
+// Nodes returns the nodes in the hash ring of this node.
+func (d *Dcron) Nodes() (RingNodes, error) {
+	if d.runningLocally {
+		return RingNodes{}, ErrNodePoolIsNil
+	}
+	snapshot, err := d.nodePool.GetRingSnapshot()
+	if err != nil {
+		return RingNodes{}, err
+	}
+	return RingNodes{
+		RingVersion: snapshot.Version,
+		UpdatedAt:   snapshot.UpdatedAt,
+		Nodes:       snapshot.Nodes,
+		Zones:       snapshot.Zones,
+	}, nil
+}
+
+func (d *Dcron) allowThisNodeRun(job JobWarpper, tick time.Time) (ok bool) {
 	if d.runningLocally {
 		return true
 	}
-	ok, err := d.nodePool.CheckJobAvailable(jobName)
+	if job.StrictOnce {
+		return d.claimThisNodeRun(job, tick)
+	}
+	jobName := job.Name
+	ok, err := d.checkJobAvailable(job)
+	if err == ErrNodePoolNoQuorum || err == ErrNodePoolIsDraining {
+		// the other nodes may be still running this job,
+		// so it will not be rerun later.
+		d.logger.Warnf("job %s skipped, err=%v", jobName, err)
+		return false
+	}
 	if err != nil {
 		d.logger.Errorf("allow this node run error, err=%v", err)
 		ok = false
@@ -226,30 +348,129 @@ func (d *Dcron) allowThisNodeRun(jobName string) (ok bool) {
 	}
 	if d.recentJobs != nil {
 		if d.state.Load().(string) == dcronStateUpgrade {
-			d.recentJobs.AddJob(jobName, time.Now())
+			d.recentJobs.AddJob(jobName, d.clock.Now())
 		}
 	}
 	return
 }
 
+// checkJobAvailable checks if the job is owned by this node,
+// the job may be owned by more than one node, see WithReplicas.
+func (d *Dcron) checkJobAvailable(job JobWarpper) (bool, error) {
+	if replicas := job.replicas(); replicas > 1 {
+		rank, err := d.nodePool.CheckJobReplica(job.Name, job.HomeZone, replicas)
+		return rank >= 0, err
+	}
+	return d.nodePool.CheckJobAvailableInZone(job.Name, job.HomeZone)
+}
+
+// runWithStandby runs the job if this node is its primary owner, or if
+// this node is a standby owner and the job is not done after the grace.
+func (d *Dcron) runWithStandby(job JobWarpper, tick time.Time) {
+	if d.runningLocally {
+		job.Execute()
+		return
+	}
+	jobName := job.Name
+	rank, err := d.nodePool.CheckJobReplica(jobName, job.HomeZone, job.replicas())
+	if err != nil {
+		d.logger.Warnf("job %s skipped, err=%v", jobName, err)
+		return
+	}
+	if rank < 0 {
+		return
+	}
+	if rank > 0 {
+		clock.Sleep(d.clock, time.Duration(rank)*job.StandbyGrace)
+		done, err := d.nodePool.IsJobDone(context.Background(), jobName, tick)
+		if err != nil {
+			d.logger.Errorf("check job %s done error, err=%v", jobName, err)
+			return
+		}
+		if done {
+			return
+		}
+		d.logger.Warnf("job %s is not done by the primary, running in standby rank=%d", jobName, rank)
+	}
+	job.Execute()
+	if err := d.nodePool.ReportJobDone(context.Background(), jobName, tick); err != nil {
+		d.logger.Errorf("report job %s done error, err=%v", jobName, err)
+	}
+}
+
+// executionContext returns the context of a job execution in this node,
+// carrying a fencing token if the driver implements CounterDriver.
+func (d *Dcron) executionContext() context.Context {
+	ctx := context.Background()
+	if d == nil || d.runningLocally {
+		return ctx
+	}
+	token, err := d.nodePool.NextFencingToken()
+	if err == ErrDriverNotSupportCounter {
+		return ctx
+	}
+	if err != nil {
+		d.logger.Errorf("get fencing token error, err=%v", err)
+		return ctx
+	}
+	return context.WithValue(ctx, fencingTokenKey{}, token)
+}
+
+// claimThisNodeRun checks if the job is available in this node
+// and claims the execution of this tick in the cluster.
+func (d *Dcron) claimThisNodeRun(job JobWarpper, tick time.Time) bool {
+	jobName := job.Name
+	ok, err := d.checkJobAvailable(job)
+	switch err {
+	case nil:
+	case ErrNodePoolIsUpgrading:
+		// the owner is uncertain while upgrading, so every node able to
+		// own the job competes for the claim, the observers never are.
+		status := d.nodePool.GetStatus()
+		ok = status.Role == RoleWorker && (job.HomeZone == "" || job.HomeZone == status.Zone)
+	default:
+		d.logger.Warnf("job %s skipped, err=%v", jobName, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	ok, err = d.nodePool.ClaimJob(context.Background(), jobName, tick)
+	if err != nil {
+		d.logger.Errorf("claim job %s error, err=%v", jobName, err)
+		return false
+	}
+	return ok
+}
+
 // Start job
 func (d *Dcron) Start() {
+	_ = d.StartContext(context.Background())
+}
+
+// StartContext starts the dcron like Start, but it returns the error of
+// the driver, or the error of ctx if the cluster has not come to steady
+// before ctx is done. ctx bounds the start only, canceling it after
+// StartContext returns does not stop the dcron.
+func (d *Dcron) StartContext(ctx context.Context) error {
 	// recover jobs before starting
 	if d.RecoverFunc != nil {
 		d.RecoverFunc(d)
 	}
 	if atomic.CompareAndSwapInt32(&d.running, dcronStopped, dcronRunning) {
 		if !d.runningLocally {
-			if err := d.startNodePool(); err != nil {
+			if err := d.startNodePool(ctx); err != nil {
 				atomic.StoreInt32(&d.running, dcronStopped)
-				return
+				return err
 			}
 			d.logger.Infof("dcron started, nodeID is %s", d.nodePool.GetNodeID())
 		}
 		d.cr.Start()
+		d.markReady()
 	} else {
 		d.logger.Infof("dcron have started")
 	}
+	return nil
 }
 
 // Run Job
@@ -260,20 +481,49 @@ func (d *Dcron) Run() {
 	}
 	if atomic.CompareAndSwapInt32(&d.running, dcronStopped, dcronRunning) {
 		if !d.runningLocally {
-			if err := d.startNodePool(); err != nil {
+			if err := d.startNodePool(context.Background()); err != nil {
 				atomic.StoreInt32(&d.running, dcronStopped)
 				return
 			}
 			d.logger.Infof("dcron running, nodeID is %s", d.nodePool.GetNodeID())
 		}
+		d.markReady()
 		d.cr.Run()
 	} else {
 		d.logger.Infof("dcron already running")
 	}
 }
 
-func (d *Dcron) startNodePool() error {
-	if err := d.nodePool.Start(context.Background()); err != nil {
+// Ready returns a channel which will be closed once the dcron
+// has started and this node is able to run jobs.
+// It is useful for starting dcron in the background. It is never
+// reopened, see IsReady for the readiness probes.
+func (d *Dcron) Ready() <-chan struct{} {
+	return d.ready
+}
+
+// IsReady reports whether this node is able to run jobs now, i.e. the
+// dcron is running and its hash ring is steady. Unlike Ready, it turns
+// false while the ring is upgrading or without quorum, and once Drain or
+// Stop begins, so it suits the readiness probes during rolling deploys.
+func (d *Dcron) IsReady() bool {
+	if atomic.LoadInt32(&d.running) != dcronRunning {
+		return false
+	}
+	if d.runningLocally {
+		return true
+	}
+	return d.nodePool.GetStatus().State == NodePoolStateSteady
+}
+
+func (d *Dcron) markReady() {
+	d.readyOnce.Do(func() {
+		close(d.ready)
+	})
+}
+
+func (d *Dcron) startNodePool(ctx context.Context) error {
+	if err := d.nodePool.Start(ctx); err != nil {
 		d.logger.Errorf("dcron start node pool error %+v", err)
 		return err
 	}
@@ -281,28 +531,61 @@ func (d *Dcron) startNodePool() error {
 }
 
 // This function is to Stop the dcron.
-// Stop stops the cron scheduler if it is running; otherwise it does nothing.
-// A context is returned so the caller can wait for running jobs to complete.
+// Stop stops the cron scheduler if it is running; otherwise it does nothing,
+// e.g. after Drain. A context is returned so the caller can wait for running
+// jobs to complete.
 func (d *Dcron) Stop() context.Context {
-	tick := time.NewTicker(time.Millisecond)
+	if !atomic.CompareAndSwapInt32(&d.running, dcronRunning, dcronStopped) {
+		return d.cr.Stop()
+	}
 	if !d.runningLocally {
 		d.nodePool.Stop(context.Background())
 	}
-	for range tick.C {
-		if atomic.CompareAndSwapInt32(&d.running, dcronRunning, dcronStopped) {
-			d.logger.Infof("dcron stopped")
-			return d.cr.Stop()
-		}
+	d.logger.Infof("dcron stopped")
+	return d.cr.Stop()
+}
+
+// Drain stops the dcron without losing ticks in the cluster, it is designed
+// for rolling deploys and should be called instead of Stop.
+// Drain deregisters this node so the other nodes take over its jobs, keeps
+// running the jobs this node owns until the other nodes have reached a
+// steady ring without this node, then stops accepting new ticks and waits
+// for the running jobs to complete.
+func (d *Dcron) Drain(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&d.running, dcronRunning, dcronStopped) {
+		return ErrDcronNotRunning
+	}
+	if d.runningLocally {
+		return waitContext(ctx, d.cr.Stop())
 	}
-	// We ensure this function won't return nil.
+	err := d.nodePool.Drain(ctx)
+	// the other nodes have taken over the jobs, or ctx is done.
+	stopped := d.cr.Stop()
+	if err != nil {
+		return err
+	}
+	if err := waitContext(ctx, stopped); err != nil {
+		return err
+	}
+	d.logger.Infof("dcron drained")
 	return nil
 }
 
+// waitContext waits until done is done or ctx is done.
+func waitContext(ctx context.Context, done context.Context) error {
+	select {
+	case <-done.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (d *Dcron) reRunRecentJobs(jobNames []string) {
 	d.logger.Infof("reRunRecentJobs: length=%d", len(jobNames))
 	for _, jobName := range jobNames {
 		if job, ok := d.jobs[jobName]; ok {
-			if ok, _ := d.nodePool.CheckJobAvailable(jobName); ok {
+			if ok, _ := d.checkJobAvailable(*job); ok {
 				job.Execute()
 			}
 		}
@@ -312,3 +595,12 @@ func (d *Dcron) reRunRecentJobs(jobNames []string) {
 func (d *Dcron) NodeID() string {
 	return d.nodePool.GetNodeID()
 }
+
+// Status returns the status of the node pool of this dcron.
+// If dcron is running locally, an empty status will be returned.
+func (d *Dcron) Status() NodePoolStatus {
+	if d.runningLocally {
+		return NodePoolStatus{}
+	}
+	return d.nodePool.GetStatus()
+}
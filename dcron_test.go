@@ -0,0 +1,935 @@
+package dcron_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dcron-contrib/commons"
+	"github.com/dcron-contrib/commons/dlog"
+	"github.com/libi/dcron"
+	"github.com/libi/dcron/clock/clocktest"
+	"github.com/libi/dcron/consistenthash"
+	"github.com/libi/dcron/cron"
+	"github.com/libi/dcron/dcrontest"
+	"github.com/stretchr/testify/suite"
+)
+
+type DcronTestSuite struct {
+	suite.Suite
+}
+
+func (s *DcronTestSuite) newDcron(md commons.DriverV2, opts ...dcron.Option) *dcron.Dcron {
+	opts = append([]dcron.Option{
+		dcron.WithLogger(dlog.NewLoggerForTest(s.T())),
+		dcron.WithNodeUpdateDuration(50 * time.Millisecond),
+	}, opts...)
+	dcr := dcron.NewDcronWithOption("testServiceName", md, opts...)
+	// dcron logs in the background until it is stopped,
+	// which must be before the test has completed.
+	s.T().Cleanup(func() { <-dcr.Stop().Done() })
+	return dcr
+}
+
+func (s *DcronTestSuite) TestStartContextReady() {
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return []string{""}, nil
+		},
+	}
+	dcr := s.newDcron(md)
+	select {
+	case <-dcr.Ready():
+		s.FailNow("dcron is ready before started")
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Nil(dcr.StartContext(ctx))
+	defer dcr.Stop()
+	select {
+	case <-dcr.Ready():
+	default:
+		s.FailNow("dcron is not ready after started")
+	}
+}
+
+func (s *DcronTestSuite) TestStartContextKeepsDriver() {
+	var startCtx context.Context
+	md := &MockDriver{
+		StartFunc: func(ctx context.Context) error {
+			startCtx = ctx
+			return nil
+		},
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return []string{""}, nil
+		},
+	}
+	dcr := s.newDcron(md)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	s.Require().Nil(dcr.StartContext(ctx))
+	defer dcr.Stop()
+	cancel()
+
+	// the registration of the node outlives the start.
+	s.Require().NotNil(startCtx)
+	s.Nil(startCtx.Err())
+}
+
+func (s *DcronTestSuite) TestStartContextDriverError() {
+	expectErr := errors.New("driver start error")
+	md := &MockDriver{
+		StartFunc: func(context.Context) error {
+			return expectErr
+		},
+	}
+	dcr := s.newDcron(md)
+	s.Equal(expectErr, dcr.StartContext(context.Background()))
+}
+
+func (s *DcronTestSuite) TestStartContextTimeout() {
+	// the nodes are changed in every round,
+	// so the cluster never comes to steady.
+	var round atomic.Int32
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return []string{"", "node" + strconv.Itoa(int(round.Add(1)))}, nil
+		},
+	}
+	dcr := s.newDcron(md)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	s.Equal(context.DeadlineExceeded, dcr.StartContext(ctx))
+	select {
+	case <-dcr.Ready():
+		s.FailNow("dcron is ready after start failed")
+	default:
+	}
+}
+
+func (s *DcronTestSuite) TestDrainThenStop() {
+	dcr := s.newDcron(dcrontest.NewMemoryDriver(dcrontest.NewMemoryStore()))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Require().Nil(dcr.StartContext(ctx))
+	s.Require().Nil(dcr.Drain(ctx))
+
+	stopped := make(chan struct{})
+	go func() {
+		dcr.Stop()
+		dcr.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		s.FailNow("Stop blocks after Drain")
+	}
+
+	// the stops after Drain do not stop the next start.
+	s.Require().Nil(dcr.StartContext(ctx))
+	defer dcr.Stop()
+	<-time.After(200 * time.Millisecond)
+	s.Equal(dcron.NodePoolStateSteady, dcr.Status().State)
+}
+
+func (s *DcronTestSuite) TestIsReady() {
+	dcr := s.newDcron(dcrontest.NewMemoryDriver(dcrontest.NewMemoryStore()))
+	s.False(dcr.IsReady())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Require().Nil(dcr.StartContext(ctx))
+	s.True(dcr.IsReady())
+
+	// not ready once draining, though Ready stays closed.
+	drained := make(chan error, 1)
+	go func() { drained <- dcr.Drain(ctx) }()
+	s.Eventually(func() bool { return !dcr.IsReady() }, time.Second, time.Millisecond)
+	s.Require().Nil(<-drained)
+	s.False(dcr.IsReady())
+	<-dcr.Ready()
+
+	s.Require().Nil(dcr.StartContext(ctx))
+	s.True(dcr.IsReady())
+	dcr.Stop()
+	s.False(dcr.IsReady())
+}
+
+func (s *DcronTestSuite) TestObserverNeverOwnsJobs() {
+	store := dcrontest.NewMemoryStore()
+	worker := s.newDcron(dcrontest.NewMemoryDriver(store))
+	observer := s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithRole(dcron.RoleObserver))
+	for _, dcr := range []*dcron.Dcron{worker, observer} {
+		for i := 0; i < 10; i++ {
+			s.Require().Nil(dcr.AddFunc("job"+strconv.Itoa(i), "* * * * *", func() {}))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Require().Nil(observer.StartContext(ctx))
+	defer observer.Stop()
+	s.Require().Nil(worker.StartContext(ctx))
+	defer worker.Stop()
+	<-time.After(200 * time.Millisecond)
+
+	s.Equal(dcron.RoleObserver, observer.Status().Role)
+	s.Equal(1, observer.Status().Nodes)
+	s.Len(observer.GetJobs(true), 0)
+	s.Equal(dcron.RoleWorker, worker.Status().Role)
+	s.Equal(1, worker.Status().Nodes)
+	s.Len(worker.GetJobs(true), 10)
+}
+
+// The nodes compete for the claims of the strict once jobs while
+// upgrading, but the observers do not.
+func (s *DcronTestSuite) TestObserverNeverClaimsWhileUpgrading() {
+	fake := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 30, 0, time.Local))
+	store := dcrontest.NewMemoryStoreWithClock(fake)
+	observer := s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithClock(fake), dcron.WithRole(dcron.RoleObserver))
+	worker := s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithClock(fake))
+	var observerCnt, workerCnt atomic.Int32
+	s.Require().Nil(observer.AddFunc("strict", "* * * * *", func() { observerCnt.Add(1) }, dcron.WithStrictOnce()))
+	s.Require().Nil(worker.AddFunc("strict", "* * * * *", func() { workerCnt.Add(1) }, dcron.WithStrictOnce()))
+	ready := func(dcr *dcron.Dcron) bool {
+		select {
+		case <-dcr.Ready():
+			return true
+		default:
+			return false
+		}
+	}
+	go func() { s.Nil(observer.StartContext(context.Background())) }()
+	s.advanceUntil(fake, func() bool { return ready(observer) })
+
+	// the worker joins, and the observer upgrades its ring.
+	go func() { s.Nil(worker.StartContext(context.Background())) }()
+	s.advanceUntil(fake, func() bool { return observer.Status().State == dcron.NodePoolStateUpgrade })
+	activation := fake.Now().Truncate(time.Minute)
+	job, err := observer.GetJob("strict", false)
+	s.Require().Nil(err)
+	job.RunScheduled(activation)
+	s.Equal(int32(0), observerCnt.Load())
+
+	s.advanceUntil(fake, func() bool { return ready(worker) })
+	job, err = worker.GetJob("strict", false)
+	s.Require().Nil(err)
+	job.RunScheduled(activation)
+	s.Equal(int32(1), workerCnt.Load())
+}
+
+func (s *DcronTestSuite) TestObserverNeedsKVDriver() {
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return []string{""}, nil
+		},
+	}
+	dcr := s.newDcron(md, dcron.WithRole(dcron.RoleObserver))
+	s.Equal(dcron.ErrDriverNotSupportKV, dcr.StartContext(context.Background()))
+}
+
+// The metadata of the peers is cached once found, and a failed read
+// fails the round instead of guessing the role of the peer.
+func (s *DcronTestSuite) TestNodeMetaReads() {
+	store := dcrontest.NewMemoryStore()
+	md := &CountingKVDriver{MemoryDriver: dcrontest.NewMemoryDriver(store)}
+	dcrs := []*dcron.Dcron{
+		s.newDcron(md),
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithZone("a")),
+		s.newDcron(dcrontest.NewMemoryDriver(store)),
+	}
+	for _, dcr := range dcrs {
+		s.Require().Nil(dcr.StartContext(context.Background()))
+		defer dcr.Stop()
+	}
+	<-time.After(time.Second)
+	// the metadata found is cached, and the missing one of the
+	// worker without zone is read once in 10 rounds.
+	reads := md.Reads.Load()
+	<-time.After(500 * time.Millisecond)
+	s.LessOrEqual(md.Reads.Load()-reads, int32(2))
+	ringNodes, err := dcrs[0].Nodes()
+	s.Require().Nil(err)
+	s.Equal(map[string]string{dcrs[1].NodeID(): "a"}, ringNodes.Zones)
+
+	// the observer is never taken as a worker while its metadata
+	// can not be read.
+	md.Fail.Store(true)
+	dcr := s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithRole(dcron.RoleObserver))
+	s.Require().Nil(dcr.StartContext(context.Background()))
+	defer dcr.Stop()
+	s.Never(func() bool {
+		ringNodes, err := dcrs[0].Nodes()
+		return err != nil || len(ringNodes.Nodes) != 3
+	}, 300*time.Millisecond, 10*time.Millisecond)
+	md.Fail.Store(false)
+	s.Never(func() bool {
+		ringNodes, err := dcrs[0].Nodes()
+		return err != nil || len(ringNodes.Nodes) != 3
+	}, 300*time.Millisecond, 10*time.Millisecond)
+}
+
+func (s *DcronTestSuite) TestAssignments() {
+	var nodes atomic.Value
+	nodes.Store([]string{"node1", "node2"})
+	getNodes := func(ctx context.Context) ([]string, error) {
+		return append([]string{}, nodes.Load().([]string)...), nil
+	}
+	dcrs := []*dcron.Dcron{
+		s.newDcron(&MockDriver{ID: "node1", GetNodesFunc: getNodes}),
+		s.newDcron(&MockDriver{ID: "node2", GetNodesFunc: getNodes}),
+	}
+	_, err := dcrs[0].Assignments()
+	s.Equal(dcron.ErrNodePoolIsNil, err)
+	for _, dcr := range dcrs {
+		for i := 0; i < 20; i++ {
+			s.Require().Nil(dcr.AddFunc("job"+strconv.Itoa(i), "* * * * *", func() {}))
+		}
+		s.Require().Nil(dcr.StartContext(context.Background()))
+		defer dcr.Stop()
+	}
+
+	ringNodes, err := dcrs[0].Nodes()
+	s.Require().Nil(err)
+	s.Equal([]string{"node1", "node2"}, ringNodes.Nodes)
+	s.Equal(uint64(1), ringNodes.RingVersion)
+	s.False(ringNodes.UpdatedAt.IsZero())
+
+	assignments, err := dcrs[0].Assignments()
+	s.Require().Nil(err)
+	s.Len(assignments.Owners, 20)
+	s.Equal(uint64(1), assignments.RingVersion)
+	for _, dcr := range dcrs {
+		for _, job := range dcr.GetJobs(true) {
+			s.Equal(dcr.NodeID(), assignments.Owners[job.Name])
+		}
+	}
+
+	nodes.Store([]string{"node1"})
+	<-time.After(200 * time.Millisecond)
+	assignments, err = dcrs[0].Assignments()
+	s.Require().Nil(err)
+	s.Equal(uint64(2), assignments.RingVersion)
+	for _, owner := range assignments.Owners {
+		s.Equal("node1", owner)
+	}
+}
+
+func (s *DcronTestSuite) TestHashFunc() {
+	getNodes := func(ctx context.Context) ([]string, error) {
+		return []string{"node1", "node2", "node3"}, nil
+	}
+	dcr := s.newDcron(&MockDriver{ID: "node1", GetNodesFunc: getNodes},
+		dcron.WithHashFunc(consistenthash.Murmur3))
+	for i := 0; i < 50; i++ {
+		s.Require().Nil(dcr.AddFunc("job-"+strconv.Itoa(i), "* * * * *", func() {}))
+	}
+	s.Require().Nil(dcr.StartContext(context.Background()))
+	defer dcr.Stop()
+
+	ring := consistenthash.New(50, consistenthash.Murmur3)
+	ring.Add("node1", "node2", "node3")
+	assignments, err := dcr.Assignments()
+	s.Require().Nil(err)
+	for job, owner := range assignments.Owners {
+		s.Equal(ring.Get(job), owner)
+	}
+}
+
+func (s *DcronTestSuite) TestFencingToken() {
+	store := dcrontest.NewMemoryStore()
+	dcrs := []*dcron.Dcron{
+		s.newDcron(dcrontest.NewMemoryDriver(store)),
+		s.newDcron(dcrontest.NewMemoryDriver(store)),
+	}
+	tokens := make(chan uint64, 1)
+	for _, dcr := range dcrs {
+		s.Require().Nil(dcr.AddContextFunc("job", "* * * * *", func(ctx context.Context) {
+			token, ok := dcron.FencingToken(ctx)
+			s.True(ok)
+			tokens <- token
+		}))
+		s.Require().Nil(dcr.StartContext(context.Background()))
+		defer dcr.Stop()
+	}
+	execute := func(dcr *dcron.Dcron) uint64 {
+		job, err := dcr.GetJob("job", false)
+		s.Require().Nil(err)
+		job.Execute()
+		return <-tokens
+	}
+
+	// the tokens increase in a node.
+	first := execute(dcrs[0])
+	second := execute(dcrs[0])
+	s.Greater(second, first)
+
+	// the epoch is shared by the nodes, the node seeing
+	// a newer ring gets greater tokens.
+	dcrs[0].Stop()
+	<-time.After(200 * time.Millisecond)
+	s.Greater(execute(dcrs[1]), second)
+}
+
+func (s *DcronTestSuite) TestFencingTokenNeedsCounterDriver() {
+	dcr := s.newDcron(&MockDriver{ID: "node1", GetNodesFunc: func(ctx context.Context) ([]string, error) {
+		return []string{"node1"}, nil
+	}})
+	tokens := make(chan bool, 1)
+	s.Require().Nil(dcr.AddContextFunc("job", "* * * * *", func(ctx context.Context) {
+		_, ok := dcron.FencingToken(ctx)
+		tokens <- ok
+	}))
+	s.Require().Nil(dcr.StartContext(context.Background()))
+	defer dcr.Stop()
+
+	job, err := dcr.GetJob("job", false)
+	s.Require().Nil(err)
+	job.Execute()
+	s.False(<-tokens)
+}
+
+func (s *DcronTestSuite) TestFencingTokenOverflow() {
+	md := &EpochDriver{MemoryDriver: dcrontest.NewMemoryDriver(dcrontest.NewMemoryStore()), Epoch: 1 << 32}
+	dcr := s.newDcron(md)
+	tokens := make(chan bool, 1)
+	s.Require().Nil(dcr.AddContextFunc("job", "* * * * *", func(ctx context.Context) {
+		_, ok := dcron.FencingToken(ctx)
+		tokens <- ok
+	}))
+	s.Require().Nil(dcr.StartContext(context.Background()))
+	defer dcr.Stop()
+
+	// the epoch does not fit in the token, which would go backwards.
+	s.Equal(uint64(1<<32), dcr.Status().Epoch)
+	job, err := dcr.GetJob("job", false)
+	s.Require().Nil(err)
+	job.Execute()
+	s.False(<-tokens)
+}
+
+func (s *DcronTestSuite) TestStrictOnce() {
+	// the nodes have different views of the cluster, and both
+	// of them take the job as their own, but claim in one store.
+	claims := dcrontest.NewMemoryDriver(dcrontest.NewMemoryStore())
+	dcrs := []*dcron.Dcron{
+		s.newDcron(&SplitClaimDriver{MemoryDriver: dcrontest.NewMemoryDriver(dcrontest.NewMemoryStore()), Claims: claims}),
+		s.newDcron(&SplitClaimDriver{MemoryDriver: dcrontest.NewMemoryDriver(dcrontest.NewMemoryStore()), Claims: claims}),
+	}
+	var strictCnt, normalCnt atomic.Int32
+	for _, dcr := range dcrs {
+		s.Require().Nil(dcr.AddFunc("strict", "* * * * *", func() { strictCnt.Add(1) }, dcron.WithStrictOnce()))
+		s.Require().Nil(dcr.AddFunc("normal", "* * * * *", func() { normalCnt.Add(1) }))
+		s.Require().Nil(dcr.StartContext(context.Background()))
+		defer dcr.Stop()
+	}
+
+	activation := time.Now().Truncate(time.Minute)
+	for _, dcr := range dcrs {
+		for _, jobName := range []string{"strict", "normal"} {
+			job, err := dcr.GetJob(jobName, false)
+			s.Require().Nil(err)
+			job.RunScheduled(activation)
+		}
+	}
+	s.Equal(int32(1), strictCnt.Load())
+	s.Equal(int32(2), normalCnt.Load())
+}
+
+func (s *DcronTestSuite) TestStrictOnceNeedsClaimDriver() {
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return []string{""}, nil
+		},
+	}
+	dcr := s.newDcron(md)
+	var cnt atomic.Int32
+	s.Require().Nil(dcr.AddFunc("strict", "* * * * *", func() { cnt.Add(1) }, dcron.WithStrictOnce()))
+	s.Require().Nil(dcr.StartContext(context.Background()))
+	defer dcr.Stop()
+
+	job, err := dcr.GetJob("strict", true)
+	s.Require().Nil(err)
+	job.Run()
+	s.Equal(int32(0), cnt.Load())
+}
+
+func (s *DcronTestSuite) TestStrictOnceWithMemoryDriver() {
+	store := dcrontest.NewMemoryStore()
+	dcrs := []*dcron.Dcron{
+		s.newDcron(dcrontest.NewMemoryDriver(store)),
+		s.newDcron(dcrontest.NewMemoryDriver(store)),
+	}
+	var cnt atomic.Int32
+	for _, dcr := range dcrs {
+		s.Require().Nil(dcr.AddFunc("strict", "* * * * *", func() { cnt.Add(1) }, dcron.WithStrictOnce()))
+		s.Require().Nil(dcr.StartContext(context.Background()))
+		defer dcr.Stop()
+	}
+
+	activation := time.Now().Truncate(time.Minute)
+	for _, dcr := range dcrs {
+		job, err := dcr.GetJob("strict", false)
+		s.Require().Nil(err)
+		job.RunScheduled(activation)
+	}
+	s.Equal(int32(1), cnt.Load())
+}
+
+func (s *DcronTestSuite) TestStrictOnceSubSecond() {
+	fake := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 30, 0, time.Local))
+	store := dcrontest.NewMemoryStoreWithClock(fake)
+	dcrs := []*dcron.Dcron{
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithClock(fake)),
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithClock(fake)),
+	}
+	for _, dcr := range dcrs {
+		dcr := dcr
+		go func() { s.Nil(dcr.StartContext(context.Background())) }()
+	}
+	ready := func(dcr *dcron.Dcron) bool {
+		select {
+		case <-dcr.Ready():
+			return true
+		default:
+			return false
+		}
+	}
+	s.advanceUntil(fake, func() bool { return ready(dcrs[0]) && ready(dcrs[1]) })
+
+	// the jobs are added at the same time, so both nodes wait for the same ticks.
+	var cnt atomic.Int32
+	for _, dcr := range dcrs {
+		s.Require().Nil(dcr.AddFunc("strict", "@every 200ms", func() { cnt.Add(1) }, dcron.WithStrictOnce()))
+	}
+	// each tick of 200ms is claimed on its own, so the job
+	// runs once in every tick instead of once a second.
+	for i := 0; i < 5; i++ {
+		// the ticker of the node pool and the timer of cron of each node.
+		fake.BlockUntil(4)
+		time.Sleep(time.Millisecond)
+		fake.Advance(200 * time.Millisecond)
+	}
+	fake.BlockUntil(4)
+	for _, dcr := range dcrs {
+		<-dcr.Stop().Done()
+	}
+	s.Equal(int32(5), cnt.Load())
+}
+
+func (s *DcronTestSuite) TestJobConflict() {
+	dcr := s.newDcron(&MockDriver{ID: "node1"})
+	s.Equal(dcron.ErrJobConflict, dcr.AddFunc("standby", "* * * * *", func() {},
+		dcron.WithStrictOnce(), dcron.WithHotStandby(time.Second)))
+	s.Equal(dcron.ErrJobConflict, dcr.AddFunc("replicas", "* * * * *", func() {},
+		dcron.WithStrictOnce(), dcron.WithReplicas(2)))
+	s.Nil(dcr.AddFunc("strict", "* * * * *", func() {}, dcron.WithStrictOnce(), dcron.WithReplicas(1)))
+	s.Nil(dcr.AddFunc("replicas", "* * * * *", func() {}, dcron.WithReplicas(2), dcron.WithHotStandby(time.Second)))
+	_, err := dcr.GetJob("standby", false)
+	s.Equal(dcron.ErrJobNotExist, err)
+}
+
+func (s *DcronTestSuite) TestCalendar() {
+	holidays, err := cron.ParseDateCalendar("2024-01-01")
+	s.Require().Nil(err)
+	dcr := s.newDcron(&MockDriver{ID: "node1"}, dcron.WithCalendar(cron.WeekendCalendar{}))
+	s.Require().Nil(dcr.AddFunc("weekdays", "0 9 * * *", func() {}))
+	s.Require().Nil(dcr.AddFunc("workdays", "0 9 * * *", func() {}, dcron.WithJobCalendar(holidays)))
+
+	// Fri Dec 29 2023, the next Monday is the new year's day.
+	friday := time.Date(2023, 12, 29, 10, 0, 0, 0, time.Local)
+	job, err := dcr.GetJob("weekdays", false)
+	s.Require().Nil(err)
+	s.Equal(time.Date(2024, 1, 1, 9, 0, 0, 0, time.Local), job.Next(friday))
+	job, err = dcr.GetJob("workdays", false)
+	s.Require().Nil(err)
+	s.Equal(time.Date(2024, 1, 2, 9, 0, 0, 0, time.Local), job.Next(friday))
+}
+
+func (s *DcronTestSuite) TestAddScheduleJob() {
+	weekdays, err := cron.ParseStandard("0 9 * * 1-5")
+	s.Require().Nil(err)
+	weekends, err := cron.ParseStandard("0 12 * * 0,6")
+	s.Require().Nil(err)
+	dcr := s.newDcron(&MockDriver{ID: "node1"})
+	s.Require().Nil(dcr.AddScheduleJob("union", cron.Union(weekdays, weekends), cron.FuncJob(func() {})))
+	s.Equal(dcron.ErrJobExist, dcr.AddScheduleJob("union", weekdays, cron.FuncJob(func() {})))
+
+	job, err := dcr.GetJob("union", false)
+	s.Require().Nil(err)
+	friday := time.Date(2023, 12, 29, 10, 0, 0, 0, time.Local)
+	s.Equal(time.Date(2023, 12, 30, 12, 0, 0, 0, time.Local), job.Next(friday))
+}
+
+func (s *DcronTestSuite) TestDescribe() {
+	dcr := s.newDcron(&MockDriver{ID: "node1"})
+	s.Require().Nil(dcr.AddFunc("weekdays", "0 5 * * 1-5", func() {}))
+	job, err := dcr.GetJob("weekdays", false)
+	s.Require().Nil(err)
+	s.Equal("At 05:00 on Monday through Friday", job.Describe(cron.English))
+	s.Equal("每周一至周五 05:00", job.Describe(cron.Chinese))
+
+	schedule, err := cron.ParseStandard("@daily")
+	s.Require().Nil(err)
+	s.Require().Nil(dcr.AddScheduleJob("schedule", schedule, cron.FuncJob(func() {})))
+	job, err = dcr.GetJob("schedule", false)
+	s.Require().Nil(err)
+	s.Equal("", job.Describe(cron.English))
+}
+
+func (s *DcronTestSuite) TestHashedSpec() {
+	dcr := s.newDcron(&MockDriver{ID: "node1"})
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	now := time.Now()
+	for _, name := range []string{"job-a", "job-b", "job-c"} {
+		s.Require().Nil(dcr.AddFunc(name, "H H(0-6) * * *", func() {}))
+		job, err := dcr.GetJob(name, false)
+		s.Require().Nil(err)
+		schedule, err := parser.ParseWithKey("H H(0-6) * * *", name)
+		s.Require().Nil(err)
+		s.Equal(schedule.Next(now), job.Next(now))
+		s.Less(job.Next(now).Hour(), 7)
+		next := job.Next(now)
+		description, err := cron.Describe(fmt.Sprintf("%d %d * * *", next.Minute(), next.Hour()), cron.English)
+		s.Require().Nil(err)
+		s.Equal(description, job.Describe(cron.English))
+	}
+	s.NotNil(dcr.AddFunc("bad-range", "H(0-60) * * * *", func() {}))
+}
+
+// advanceUntil advances the fake clock by the update rounds until cond is true.
+func (s *DcronTestSuite) advanceUntil(fake *clocktest.Fake, cond func() bool) {
+	for i := 0; !cond(); i++ {
+		s.Require().Less(i, 1000, "condition is not met")
+		fake.Advance(50 * time.Millisecond)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *DcronTestSuite) TestFakeClockFailover() {
+	fake := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 30, 0, time.Local))
+	store := dcrontest.NewMemoryStoreWithClock(fake)
+	var runs [2]atomic.Int32
+	dcrs := make([]*dcron.Dcron, 2)
+	for i := range dcrs {
+		i := i
+		dcrs[i] = s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithClock(fake))
+		for j := 0; j < 20; j++ {
+			s.Require().Nil(dcrs[i].AddFunc("job-"+strconv.Itoa(j), "* * * * *", func() { runs[i].Add(1) }))
+		}
+		go func() { s.Nil(dcrs[i].StartContext(context.Background())) }()
+	}
+	defer dcrs[0].Stop()
+	ready := func(dcr *dcron.Dcron) bool {
+		select {
+		case <-dcr.Ready():
+			return true
+		default:
+			return false
+		}
+	}
+	s.advanceUntil(fake, func() bool { return ready(dcrs[0]) && ready(dcrs[1]) })
+
+	// the ticker of the node pool and the timer of cron of each node.
+	fake.BlockUntil(4)
+	fake.Advance(time.Date(2024, 1, 1, 0, 1, 0, 0, time.Local).Sub(fake.Now()))
+	s.Eventually(func() bool { return runs[0].Load()+runs[1].Load() == 20 }, time.Second, time.Millisecond)
+	s.NotZero(runs[0].Load())
+	s.NotZero(runs[1].Load())
+
+	// node 1 leaves, node 0 takes over all jobs in the next minute.
+	dcrs[1].Stop()
+	s.advanceUntil(fake, func() bool {
+		status := dcrs[0].Status()
+		return status.Nodes == 1 && status.State == dcron.NodePoolStateSteady
+	})
+	before := runs[0].Load()
+	fake.BlockUntil(2)
+	fake.Advance(time.Date(2024, 1, 1, 0, 2, 0, 0, time.Local).Sub(fake.Now()))
+	s.Eventually(func() bool { return runs[0].Load() == before+20 }, time.Second, time.Millisecond)
+}
+
+// The tick claimed by a run is its activation time, so a run dispatched
+// late claims the same tick as the others.
+func (s *DcronTestSuite) TestStrictOnceClaimsActivation() {
+	fake := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 30, 0, time.Local))
+	dcr := s.newDcron(dcrontest.NewMemoryDriver(dcrontest.NewMemoryStoreWithClock(fake)), dcron.WithClock(fake))
+	var cnt atomic.Int32
+	s.Require().Nil(dcr.AddFunc("strict", "* * * * *", func() { cnt.Add(1) }, dcron.WithStrictOnce()))
+	go func() { s.Nil(dcr.StartContext(context.Background())) }()
+	defer dcr.Stop()
+	s.advanceUntil(fake, func() bool {
+		select {
+		case <-dcr.Ready():
+			return true
+		default:
+			return false
+		}
+	})
+
+	job, err := dcr.GetJob("strict", false)
+	s.Require().Nil(err)
+	activation := fake.Now().Truncate(time.Minute)
+	job.RunScheduled(activation)
+	fake.Advance(1500 * time.Millisecond)
+	job.RunScheduled(activation)
+	s.Equal(int32(1), cnt.Load())
+	job.RunScheduled(activation.Add(time.Minute))
+	s.Equal(int32(2), cnt.Load())
+}
+
+func (s *DcronTestSuite) TestClockJump() {
+	fake := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 30, 0, time.Local))
+	var runs atomic.Int32
+	jumps := make(chan string, 1)
+	dcr := s.newDcron(dcrontest.NewMemoryDriver(dcrontest.NewMemoryStoreWithClock(fake)),
+		dcron.WithClock(fake),
+		dcron.WithClockJumpPolicy(cron.RunAll, time.Minute),
+		dcron.WithClockJumpHandler(func(jobName string, jump cron.ClockJump) {
+			s.Equal(60, jump.Missed)
+			s.Equal(61, jump.Runs)
+			jumps <- jobName
+		}))
+	// each run of RunAll claims its own activation.
+	s.Require().Nil(dcr.AddFunc("job", "* * * * *", func() { runs.Add(1) }, dcron.WithStrictOnce()))
+	go func() { s.Nil(dcr.StartContext(context.Background())) }()
+	defer dcr.Stop()
+	s.advanceUntil(fake, func() bool {
+		select {
+		case <-dcr.Ready():
+			return true
+		default:
+			return false
+		}
+	})
+
+	// the job due at 00:01 wakes up at 01:01 after the clock is set an hour ahead.
+	fake.BlockUntil(2)
+	fake.Set(fake.Now().Add(time.Hour))
+	fake.Advance(time.Date(2024, 1, 1, 1, 1, 0, 0, time.Local).Sub(fake.Now()))
+	select {
+	case jobName := <-jumps:
+		s.Equal("job", jobName)
+	case <-time.After(time.Second):
+		s.Fail("expected a clock jump")
+	}
+	s.Eventually(func() bool { return runs.Load() == 61 }, time.Second, time.Millisecond)
+}
+
+func (s *DcronTestSuite) TestHomeZone() {
+	store := dcrontest.NewMemoryStore()
+	dcrs := []*dcron.Dcron{
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithZone("a")),
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithZone("a")),
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithZone("b")),
+	}
+	for _, dcr := range dcrs {
+		for i := 0; i < 10; i++ {
+			s.Require().Nil(dcr.AddFunc("a"+strconv.Itoa(i), "* * * * *", func() {}, dcron.WithHomeZone("a")))
+			s.Require().Nil(dcr.AddFunc("b"+strconv.Itoa(i), "* * * * *", func() {}, dcron.WithHomeZone("b")))
+		}
+		s.Require().Nil(dcr.StartContext(context.Background()))
+	}
+	defer dcrs[0].Stop()
+	defer dcrs[1].Stop()
+	<-time.After(200 * time.Millisecond)
+
+	ringNodes, err := dcrs[0].Nodes()
+	s.Require().Nil(err)
+	s.Len(ringNodes.Nodes, 3)
+	s.Equal("b", ringNodes.Zones[dcrs[2].NodeID()])
+	assignments, err := dcrs[0].Assignments()
+	s.Require().Nil(err)
+	for jobName, owner := range assignments.Owners {
+		if jobName[0] == 'a' {
+			s.NotEqual(dcrs[2].NodeID(), owner)
+		} else {
+			s.Equal(dcrs[2].NodeID(), owner)
+		}
+	}
+	for _, job := range dcrs[2].GetJobs(true) {
+		s.Equal("b", job.HomeZone)
+	}
+
+	// fail over to the other zones when no node in zone b is alive.
+	dcrs[2].Stop()
+	<-time.After(200 * time.Millisecond)
+	assignments, err = dcrs[0].Assignments()
+	s.Require().Nil(err)
+	for _, owner := range assignments.Owners {
+		s.NotEqual(dcrs[2].NodeID(), owner)
+	}
+	s.Len(append(dcrs[0].GetJobs(true), dcrs[1].GetJobs(true)...), 20)
+}
+
+func (s *DcronTestSuite) TestReplicas() {
+	store := dcrontest.NewMemoryStore()
+	var cnt atomic.Int32
+	dcrs := make([]*dcron.Dcron, 3)
+	for i := range dcrs {
+		dcrs[i] = s.newDcron(dcrontest.NewMemoryDriver(store))
+		s.Require().Nil(dcrs[i].AddFunc("replicated", "* * * * *", func() { cnt.Add(1) }, dcron.WithReplicas(2)))
+		s.Require().Nil(dcrs[i].StartContext(context.Background()))
+		defer dcrs[i].Stop()
+	}
+	<-time.After(200 * time.Millisecond)
+
+	owners := 0
+	for _, dcr := range dcrs {
+		owners += len(dcr.GetJobs(true))
+		job, err := dcr.GetJob("replicated", false)
+		s.Require().Nil(err)
+		job.Run()
+	}
+	s.Equal(2, owners)
+	s.Equal(int32(2), cnt.Load())
+}
+
+func (s *DcronTestSuite) TestHotStandby() {
+	fake := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 30, 0, time.Local))
+	store := dcrontest.NewMemoryStoreWithClock(fake)
+	cnts := make([]atomic.Int32, 3)
+	dcrs := make([]*dcron.Dcron, 3)
+	for i := range dcrs {
+		i := i
+		dcrs[i] = s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithClock(fake))
+		s.Require().Nil(dcrs[i].AddFunc("standby", "* * * * *", func() { cnts[i].Add(1) },
+			dcron.WithHotStandby(100*time.Millisecond)))
+		go func() { s.Nil(dcrs[i].StartContext(context.Background())) }()
+	}
+	s.advanceUntil(fake, func() bool {
+		for _, dcr := range dcrs {
+			select {
+			case <-dcr.Ready():
+			default:
+				return false
+			}
+			if dcr.Status().Nodes != len(dcrs) || !dcr.IsReady() {
+				return false
+			}
+		}
+		return true
+	})
+	ran := make(map[string]*atomic.Int32)
+	for i, dcr := range dcrs {
+		ran[dcr.NodeID()] = &cnts[i]
+	}
+	assignments, err := dcrs[0].Assignments()
+	s.Require().Nil(err)
+	primary := assignments.Owners["standby"]
+
+	// the primary runs first, and the standbys wait for their
+	// graces on the fake clock.
+	run := func(activation time.Time, skipPrimary bool) {
+		var wg sync.WaitGroup
+		for _, dcr := range dcrs {
+			job, err := dcr.GetJob("standby", false)
+			s.Require().Nil(err)
+			if dcr.NodeID() == primary {
+				if !skipPrimary {
+					job.RunScheduled(activation)
+				}
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				job.RunScheduled(activation)
+			}()
+		}
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		s.advanceUntil(fake, func() bool {
+			select {
+			case <-done:
+				return true
+			default:
+				return false
+			}
+		})
+	}
+	total := func() (n int32) {
+		for _, cnt := range ran {
+			n += cnt.Load()
+		}
+		return
+	}
+
+	// the standby sees the completion of the primary.
+	activation := fake.Now().Truncate(time.Minute)
+	run(activation, false)
+	s.Equal(int32(1), ran[primary].Load())
+	s.Equal(int32(1), total())
+
+	// the standby runs the job when the primary does not.
+	run(activation.Add(time.Minute), true)
+	s.Equal(int32(1), ran[primary].Load())
+	s.Equal(int32(2), total())
+}
+
+func (s *DcronTestSuite) TestRingAgreement() {
+	store := dcrontest.NewMemoryStore()
+	dcrs := []*dcron.Dcron{
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithRingAgreement()),
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithRingAgreement()),
+	}
+	for _, dcr := range dcrs {
+		s.Require().Nil(dcr.StartContext(context.Background()))
+		defer dcr.Stop()
+	}
+	<-time.After(300 * time.Millisecond)
+	for _, dcr := range dcrs {
+		status := dcr.Status()
+		s.Equal(dcron.NodePoolStateSteady, status.State)
+		s.Equal(2, status.Nodes)
+		s.Empty(status.RingMismatch)
+	}
+}
+
+func (s *DcronTestSuite) TestRingAgreementMismatch() {
+	store := dcrontest.NewMemoryStore()
+	dcrs := []*dcron.Dcron{
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithRingAgreement()),
+		s.newDcron(dcrontest.NewMemoryDriver(store), dcron.WithRingAgreement(),
+			dcron.WithHashFunc(consistenthash.Murmur3)),
+	}
+	errs := make(chan error, len(dcrs))
+	for _, dcr := range dcrs {
+		go func(dcr *dcron.Dcron) {
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			errs <- dcr.StartContext(ctx)
+		}(dcr)
+	}
+	for range dcrs {
+		s.Equal(context.DeadlineExceeded, <-errs)
+	}
+	for _, dcr := range dcrs {
+		status := dcr.Status()
+		s.Equal(dcron.NodePoolStateUpgrade, status.State)
+		s.Contains(status.RingMismatch, "different hash replicas or hash function")
+	}
+}
+
+func (s *DcronTestSuite) TestRingAgreementNeedsKVDriver() {
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return []string{""}, nil
+		},
+	}
+	dcr := s.newDcron(md, dcron.WithRingAgreement())
+	s.Equal(dcron.ErrDriverNotSupportKV, dcr.StartContext(context.Background()))
+}
+
+func TestDcronTestSuite(t *testing.T) {
+	suite.Run(t, new(DcronTestSuite))
+}
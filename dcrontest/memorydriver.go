@@ -0,0 +1,151 @@
+// Package dcrontest provides utilities for testing dcron.
+package dcrontest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dcron-contrib/commons"
+	"github.com/libi/dcron/clock"
+)
+
+// MemoryStore is an in-memory stand-in of the storage behind a driver,
+// like redis or etcd. The MemoryDrivers sharing a MemoryStore see the
+// same nodes and keys, so a cluster can be tested in one process.
+type MemoryStore struct {
+	mu       sync.Mutex
+	nodes    map[string]map[string]struct{} // serviceName -> nodeIDs
+	kv       map[string]memoryValue
+	counters map[string]int64
+	clock    clock.Clock // for the expiration of keys
+}
+
+type memoryValue struct {
+	value    string
+	expireAt time.Time // zero means never expire
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(clock.Real())
+}
+
+// NewMemoryStoreWithClock creates an empty MemoryStore expiring the keys
+// on the clock, e.g. a fake clock of package clocktest.
+func NewMemoryStoreWithClock(c clock.Clock) *MemoryStore {
+	return &MemoryStore{
+		nodes:    make(map[string]map[string]struct{}),
+		kv:       make(map[string]memoryValue),
+		counters: make(map[string]int64),
+		clock:    c,
+	}
+}
+
+// get returns the value of key, it must be called with mu held.
+func (ms *MemoryStore) get(key string) (string, bool) {
+	v, ok := ms.kv[key]
+	if !ok {
+		return "", false
+	}
+	if !v.expireAt.IsZero() && !ms.clock.Now().Before(v.expireAt) {
+		delete(ms.kv, key)
+		return "", false
+	}
+	return v.value, true
+}
+
+// set sets the value of key, it must be called with mu held.
+func (ms *MemoryStore) set(key, value string, ttl time.Duration) {
+	v := memoryValue{value: value}
+	if ttl > 0 {
+		v.expireAt = ms.clock.Now().Add(ttl)
+	}
+	ms.kv[key] = v
+}
+
+// MemoryDriver is an in-memory driver implementing commons.DriverV2 and
+// the optional capabilities KVDriver, CounterDriver and ClaimDriver.
+type MemoryDriver struct {
+	store       *MemoryStore
+	serviceName string
+	nodeID      string
+}
+
+// NewMemoryDriver creates a MemoryDriver on the store.
+func NewMemoryDriver(store *MemoryStore) *MemoryDriver {
+	return &MemoryDriver{store: store}
+}
+
+func (md *MemoryDriver) Init(serviceName string, opts ...commons.Option) {
+	md.serviceName = serviceName
+	md.nodeID = commons.GetNodeId(serviceName)
+}
+
+func (md *MemoryDriver) NodeID() string {
+	return md.nodeID
+}
+
+func (md *MemoryDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
+	md.store.mu.Lock()
+	defer md.store.mu.Unlock()
+	nodes = make([]string, 0, len(md.store.nodes[md.serviceName]))
+	for node := range md.store.nodes[md.serviceName] {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return
+}
+
+func (md *MemoryDriver) Start(ctx context.Context) (err error) {
+	md.store.mu.Lock()
+	defer md.store.mu.Unlock()
+	if md.store.nodes[md.serviceName] == nil {
+		md.store.nodes[md.serviceName] = make(map[string]struct{})
+	}
+	md.store.nodes[md.serviceName][md.nodeID] = struct{}{}
+	return
+}
+
+func (md *MemoryDriver) Stop(ctx context.Context) (err error) {
+	md.store.mu.Lock()
+	defer md.store.mu.Unlock()
+	delete(md.store.nodes[md.serviceName], md.nodeID)
+	return
+}
+
+func (md *MemoryDriver) WithOption(opt commons.Option) (err error) {
+	return
+}
+
+func (md *MemoryDriver) SetKV(ctx context.Context, key, value string, ttl time.Duration) error {
+	md.store.mu.Lock()
+	defer md.store.mu.Unlock()
+	md.store.set(key, value, ttl)
+	return nil
+}
+
+func (md *MemoryDriver) GetKV(ctx context.Context, key string) (string, bool, error) {
+	md.store.mu.Lock()
+	defer md.store.mu.Unlock()
+	value, ok := md.store.get(key)
+	return value, ok, nil
+}
+
+func (md *MemoryDriver) Incr(ctx context.Context, key string) (int64, error) {
+	md.store.mu.Lock()
+	defer md.store.mu.Unlock()
+	md.store.counters[key]++
+	return md.store.counters[key], nil
+}
+
+func (md *MemoryDriver) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	md.store.mu.Lock()
+	defer md.store.mu.Unlock()
+	if _, ok := md.store.get(key); ok {
+		return false, nil
+	}
+	md.store.set(key, value, ttl)
+	return true, nil
+}
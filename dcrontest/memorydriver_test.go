@@ -0,0 +1,75 @@
+package dcrontest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libi/dcron"
+	"github.com/libi/dcron/dcrontest"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ dcron.KVDriver      = (*dcrontest.MemoryDriver)(nil)
+	_ dcron.CounterDriver = (*dcrontest.MemoryDriver)(nil)
+	_ dcron.ClaimDriver   = (*dcrontest.MemoryDriver)(nil)
+)
+
+func TestMemoryDriverNodes(t *testing.T) {
+	ctx := context.Background()
+	store := dcrontest.NewMemoryStore()
+	drv1 := dcrontest.NewMemoryDriver(store)
+	drv1.Init("service")
+	drv2 := dcrontest.NewMemoryDriver(store)
+	drv2.Init("service")
+	other := dcrontest.NewMemoryDriver(store)
+	other.Init("otherService")
+	require.NotEqual(t, drv1.NodeID(), drv2.NodeID())
+
+	require.Nil(t, drv1.Start(ctx))
+	require.Nil(t, drv2.Start(ctx))
+	require.Nil(t, other.Start(ctx))
+	nodes, err := drv1.GetNodes(ctx)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{drv1.NodeID(), drv2.NodeID()}, nodes)
+
+	require.Nil(t, drv2.Stop(ctx))
+	nodes, err = drv1.GetNodes(ctx)
+	require.Nil(t, err)
+	require.Equal(t, []string{drv1.NodeID()}, nodes)
+}
+
+func TestMemoryDriverKeys(t *testing.T) {
+	ctx := context.Background()
+	store := dcrontest.NewMemoryStore()
+	drv1 := dcrontest.NewMemoryDriver(store)
+	drv2 := dcrontest.NewMemoryDriver(store)
+
+	ok, err := drv1.SetNX(ctx, "claim", "node1", 100*time.Millisecond)
+	require.Nil(t, err)
+	require.True(t, ok)
+	ok, err = drv2.SetNX(ctx, "claim", "node2", 100*time.Millisecond)
+	require.Nil(t, err)
+	require.False(t, ok)
+	value, ok, err := drv2.GetKV(ctx, "claim")
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, "node1", value)
+
+	// the key expires after ttl.
+	<-time.After(150 * time.Millisecond)
+	_, ok, err = drv2.GetKV(ctx, "claim")
+	require.Nil(t, err)
+	require.False(t, ok)
+	ok, err = drv2.SetNX(ctx, "claim", "node2", 0)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	n, err := drv1.Incr(ctx, "counter")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), n)
+	n, err = drv2.Incr(ctx, "counter")
+	require.Nil(t, err)
+	require.Equal(t, int64(2), n)
+}
@@ -0,0 +1,56 @@
+package dcron
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrDriverNotSupportKV      = errors.New("driver does not support key-value")
+	ErrDriverNotSupportClaim   = errors.New("driver does not support claim")
+	ErrDriverNotSupportCounter = errors.New("driver does not support counter")
+)
+
+// metaKeyPrefix is the prefix of the keys written by dcron through
+// the driver capabilities. It is different to commons.GlobalKeyPrefix,
+// so these keys will never be taken as nodes by the drivers.
+const metaKeyPrefix = "distributed-cron-meta:"
+
+// KVDriver is an optional capability of commons.DriverV2.
+// The nodes share small pieces of state, like the role of a node,
+// through the driver implementing it.
+type KVDriver interface {
+	// SetKV sets the value of key, the key will expire after ttl.
+	// If ttl is 0, the key will never expire.
+	SetKV(ctx context.Context, key, value string, ttl time.Duration) error
+
+	// GetKV gets the value of key, ok is false if the key does not exist.
+	GetKV(ctx context.Context, key string) (value string, ok bool, err error)
+}
+
+func metaKey(serviceName string, parts ...string) string {
+	key := metaKeyPrefix + serviceName
+	for _, part := range parts {
+		key += ":" + part
+	}
+	return key
+}
+
+// CounterDriver is an optional capability of commons.DriverV2.
+// The ring epoch of the cluster is maintained by the driver implementing it,
+// every node increases the epoch on each change of its hash ring. The jobs
+// get no fencing tokens without it.
+type CounterDriver interface {
+	// Incr increases the counter of key by one atomically
+	// and returns the new value.
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// ClaimDriver is an optional capability of commons.DriverV2.
+// The strict once jobs claim their ticks through the driver implementing it.
+type ClaimDriver interface {
+	// SetNX sets the value of key only if the key does not exist,
+	// and returns true if it is set. The key will expire after ttl.
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+}
@@ -4,18 +4,90 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"github.com/libi/dcron/consistenthash"
 )
 
 var (
 	ErrNodePoolIsUpgrading = errors.New("nodePool is upgrading")
 	ErrNodePoolIsNil       = errors.New("nodePool is nil")
+	ErrNodePoolNoQuorum    = errors.New("nodePool has no quorum")
+	ErrNodePoolIsDraining  = errors.New("nodePool is draining")
+
+	ErrFencingTokenOverflow = errors.New("fencing token overflow")
 )
 
+// NodePoolStatus is a snapshot of the state of a node pool.
+type NodePoolStatus struct {
+	NodeID string
+	Role   Role
+	Zone   string
+	// State is one of NodePoolStateSteady, NodePoolStateUpgrade,
+	// NodePoolStateNoQuorum and NodePoolStateDraining.
+	State string
+	// Nodes is the number of nodes in the current hash ring.
+	Nodes int
+	// RecentMaxNodes is the maximum number of visible nodes in the
+	// recent update rounds, it is only tracked with WithMinQuorum.
+	RecentMaxNodes int
+	// FlapCount is the number of times the nodes changed again
+	// before the hash ring came to steady.
+	FlapCount int
+	// Epoch is the ring epoch of the fencing tokens, zero if the driver
+	// does not implement CounterDriver.
+	Epoch uint64
+	// RingMismatch describes the nodes whose hash ring differs from
+	// this node, it is only checked with WithRingAgreement.
+	RingMismatch        string
+	LastNodesUpdateTime time.Time
+}
+
 type INodePool interface {
 	Start(ctx context.Context) error
 	CheckJobAvailable(jobName string) (bool, error)
+	CheckJobAvailableInZone(jobName, homeZone string) (bool, error)
+	CheckJobReplica(jobName, homeZone string, replicas int) (int, error)
 	Stop(ctx context.Context) error
+	Drain(ctx context.Context) error
 
 	GetNodeID() string
 	GetLastNodesUpdateTime() time.Time
+	GetStatus() NodePoolStatus
+	GetRingSnapshot() (*RingSnapshot, error)
+	NextFencingToken() (uint64, error)
+	ClaimJob(ctx context.Context, jobName string, scheduledTime time.Time) (bool, error)
+	ReportJobDone(ctx context.Context, jobName string, scheduledTime time.Time) error
+	IsJobDone(ctx context.Context, jobName string, scheduledTime time.Time) (bool, error)
+}
+
+// RingSnapshot is a view of the hash ring of a node pool,
+// it will not change when the hash ring is updated.
+type RingSnapshot struct {
+	// Version is increased every time the hash ring is updated.
+	Version   uint64
+	UpdatedAt time.Time
+	// Nodes is the sorted nodes in the hash ring.
+	Nodes []string
+	// Zones maps nodeID to its zone, the nodes without zone are left out.
+	Zones map[string]string
+
+	ring      *consistenthash.Map
+	zoneRings map[string]*consistenthash.Map
+}
+
+// Owner returns the node which the job is assigned to,
+// or an empty string if there is no node in the hash ring.
+func (rs *RingSnapshot) Owner(jobName string) string {
+	return rs.ring.Get(jobName)
+}
+
+// Owners returns the first n nodes which the job preferring homeZone
+// is assigned to, the first one is the primary owner.
+func (rs *RingSnapshot) Owners(jobName, homeZone string, n int) []string {
+	return ownersOf(rs.ring, rs.zoneRings, jobName, homeZone, n)
+}
+
+// OwnerInZone returns the node which the job preferring homeZone is assigned to.
+func (rs *RingSnapshot) OwnerInZone(jobName, homeZone string) string {
+	return ownerOf(rs.ring, rs.zoneRings, jobName, homeZone)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -93,6 +94,255 @@ func (ts *testINodePoolSuite) TestStartFailedWithDriverGetNodesError() {
 	ts.Equal(expectErr, np.Start(context.Background()))
 }
 
+func (ts *testINodePoolSuite) TestNoQuorumWhenVisibleNodesDrop() {
+	var nodes atomic.Value
+	nodes.Store([]string{"", "node1", "node2", "node3"})
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return append([]string{}, nodes.Load().([]string)...), nil
+		},
+	}
+	updateDuration := 50 * time.Millisecond
+	dcr := dcron.NewDcronWithOption(
+		"testServiceName",
+		md,
+		dcron.WithNodeUpdateDuration(updateDuration),
+		dcron.WithMinQuorum(0.5, 1),
+	)
+	dcr.Start()
+	defer dcr.Stop()
+	ts.Equal(dcron.NodePoolStateSteady, dcr.Status().State)
+	ts.Equal(4, dcr.Status().RecentMaxNodes)
+
+	// this node is partitioned and sees only itself.
+	nodes.Store([]string{""})
+	<-time.After(updateDuration * 4)
+	status := dcr.Status()
+	ts.Equal(dcron.NodePoolStateNoQuorum, status.State)
+	ts.Equal(1, status.Nodes)
+	ts.Equal(4, status.RecentMaxNodes)
+
+	// the partition is healed.
+	nodes.Store([]string{"", "node1", "node2", "node3"})
+	<-time.After(updateDuration * 4)
+	ts.Equal(dcron.NodePoolStateSteady, dcr.Status().State)
+}
+
+func (ts *testINodePoolSuite) TestNoQuorumOutlastsWindow() {
+	var nodes atomic.Value
+	nodes.Store([]string{"", "node1", "node2", "node3"})
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return append([]string{}, nodes.Load().([]string)...), nil
+		},
+	}
+	updateDuration := 50 * time.Millisecond
+	dcr := dcron.NewDcronWithOption(
+		"testServiceName",
+		md,
+		dcron.WithNodeUpdateDuration(updateDuration),
+		dcron.WithMinQuorum(0.5, 1),
+		dcron.WithQuorumWindow(3*updateDuration),
+	)
+	dcr.Start()
+	defer dcr.Stop()
+
+	// the partition lasts longer than the window, the node still
+	// compares itself to the last healthy view.
+	nodes.Store([]string{""})
+	<-time.After(updateDuration * 10)
+	status := dcr.Status()
+	ts.Equal(dcron.NodePoolStateNoQuorum, status.State)
+	ts.Equal(4, status.RecentMaxNodes)
+
+	nodes.Store([]string{"", "node1", "node2"})
+	<-time.After(updateDuration * 4)
+	ts.Equal(dcron.NodePoolStateSteady, dcr.Status().State)
+}
+
+func (ts *testINodePoolSuite) TestQuorumAfterScaleDown() {
+	var nodes atomic.Value
+	nodes.Store([]string{"", "node1", "node2", "node3", "node4", "node5", "node6", "node7", "node8", "node9"})
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return append([]string{}, nodes.Load().([]string)...), nil
+		},
+	}
+	updateDuration := 50 * time.Millisecond
+	dcr := dcron.NewDcronWithOption(
+		"testServiceName",
+		md,
+		dcron.WithLogger(dlog.NewLoggerForTest(ts.T())),
+		dcron.WithNodeUpdateDuration(updateDuration),
+		dcron.WithMinQuorum(0.5, 1),
+		dcron.WithQuorumWindow(2*updateDuration),
+	)
+	dcr.Start()
+	defer func() { <-dcr.Stop().Done() }()
+	ts.Equal(10, dcr.Status().RecentMaxNodes)
+
+	// the cluster is scaled down below the ratio at once, the nodes
+	// refuse to run jobs for the lockout, and then take the
+	// smaller cluster as the recent maximum.
+	nodes.Store([]string{"", "node1", "node2"})
+	ts.Eventually(func() bool { return dcr.Status().State == dcron.NodePoolStateNoQuorum }, time.Second, updateDuration)
+	ts.Eventually(func() bool { return dcr.Status().State == dcron.NodePoolStateSteady }, 3*time.Second, updateDuration)
+	ts.Equal(3, dcr.Status().RecentMaxNodes)
+}
+
+func (ts *testINodePoolSuite) TestMinQuorumInvalid() {
+	ts.Panics(func() { dcron.WithMinQuorum(0, 1) })
+	ts.Panics(func() { dcron.WithMinQuorum(1.5, 1) })
+	ts.Panics(func() { dcron.WithMinQuorum(0.5, -1) })
+	ts.NotPanics(func() { dcron.WithMinQuorum(1, 0) })
+}
+
+func (ts *testINodePoolSuite) TestDrain() {
+	var deregistered atomic.Bool
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			if deregistered.Load() {
+				return []string{"node1"}, nil
+			}
+			return []string{"", "node1"}, nil
+		},
+		StopFunc: func(ctx context.Context) error {
+			deregistered.Store(true)
+			return nil
+		},
+	}
+	np := dcron.NewNodePool(
+		"testServiceName",
+		md, 50*time.Millisecond,
+		ts.defaultHashReplicas,
+		dlog.NewLoggerForTest(ts.T()))
+	ts.Require().Nil(np.Start(context.Background()))
+	ring := consistenthash.New(ts.defaultHashReplicas, nil)
+	ring.Add("", "node1")
+	jobName := "testjob"
+	for i := 0; ring.Get(jobName) != ""; i++ {
+		jobName = "testjob" + strconv.Itoa(i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- np.Drain(ctx) }()
+
+	// the node keeps its jobs until the peers have taken over.
+	ts.Eventually(func() bool { return deregistered.Load() }, time.Second, time.Millisecond)
+	ts.Equal(dcron.NodePoolStateDraining, np.GetStatus().State)
+	ok, err := np.CheckJobAvailable(jobName)
+	ts.Nil(err)
+	ts.True(ok)
+
+	ts.Nil(<-drained)
+	ts.Equal(dcron.NodePoolStateDraining, np.GetStatus().State)
+	_, err = np.CheckJobAvailable(jobName)
+	ts.Equal(dcron.ErrNodePoolIsDraining, err)
+}
+
+func (ts *testINodePoolSuite) TestDrainLastNode() {
+	var deregistered atomic.Bool
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			if deregistered.Load() {
+				return nil, nil
+			}
+			return []string{""}, nil
+		},
+		StopFunc: func(ctx context.Context) error {
+			deregistered.Store(true)
+			return nil
+		},
+	}
+	np := dcron.NewNodePool(
+		"testServiceName",
+		md, 50*time.Millisecond,
+		ts.defaultHashReplicas,
+		dlog.NewLoggerForTest(ts.T()))
+	ts.Require().Nil(np.Start(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ts.Nil(np.Drain(ctx))
+}
+
+func (ts *testINodePoolSuite) TestDrainTimeoutWhenPeersNotSteady() {
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			// the driver never removes this node.
+			return []string{"", "node1"}, nil
+		},
+	}
+	np := dcron.NewNodePool(
+		"testServiceName",
+		md, 50*time.Millisecond,
+		ts.defaultHashReplicas,
+		nil)
+	ts.Require().Nil(np.Start(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	ts.Equal(context.DeadlineExceeded, np.Drain(ctx))
+}
+
+func (ts *testINodePoolSuite) TestMinUpgradeDuration() {
+	var nodes atomic.Value
+	nodes.Store([]string{"", "node1"})
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			return append([]string{}, nodes.Load().([]string)...), nil
+		},
+	}
+	updateDuration := 50 * time.Millisecond
+	dcr := dcron.NewDcronWithOption(
+		"testServiceName",
+		md,
+		dcron.WithNodeUpdateDuration(updateDuration),
+		dcron.WithStableRounds(2),
+		dcron.WithMinUpgradeDuration(20*updateDuration),
+	)
+	dcr.Start()
+	defer dcr.Stop()
+	ts.Equal(dcron.NodePoolStateSteady, dcr.Status().State)
+
+	nodes.Store([]string{""})
+	<-time.After(10 * updateDuration)
+	ts.Equal(dcron.NodePoolStateUpgrade, dcr.Status().State)
+	<-time.After(20 * updateDuration)
+	ts.Equal(dcron.NodePoolStateSteady, dcr.Status().State)
+	ts.Equal(0, dcr.Status().FlapCount)
+}
+
+func (ts *testINodePoolSuite) TestFlapCount() {
+	var round atomic.Int32
+	var flapping atomic.Bool
+	md := &MockDriver{
+		GetNodesFunc: func(ctx context.Context) ([]string, error) {
+			if flapping.Load() && round.Add(1)%2 == 0 {
+				return []string{""}, nil
+			}
+			return []string{"", "node1"}, nil
+		},
+	}
+	updateDuration := 50 * time.Millisecond
+	dcr := dcron.NewDcronWithOption(
+		"testServiceName",
+		md,
+		dcron.WithNodeUpdateDuration(updateDuration),
+		dcron.WithStableRounds(3),
+	)
+	dcr.Start()
+	defer dcr.Stop()
+	ts.Equal(0, dcr.Status().FlapCount)
+
+	flapping.Store(true)
+	<-time.After(10 * updateDuration)
+	ts.Equal(dcron.NodePoolStateUpgrade, dcr.Status().State)
+	ts.Greater(dcr.Status().FlapCount, 0)
+}
+
 func TestTestINodePoolSuite(t *testing.T) {
 	s := new(testINodePoolSuite)
 	suite.Run(t, s)
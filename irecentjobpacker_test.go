@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/libi/dcron"
+	"github.com/libi/dcron/clock/clocktest"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -59,6 +60,20 @@ func (s *IRecentJobPackerTestSuite) Test_CallMultiTimes() {
 	wg.Wait()
 }
 
+func (s *IRecentJobPackerTestSuite) TestFakeClock() {
+	fake := clocktest.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local))
+	recentPacker := dcron.NewRecentJobPackerWithClock(time.Minute, fake)
+	recentPacker.AddJob("a", fake.Now())
+	fake.Advance(30 * time.Second)
+	recentPacker.AddJob("b", fake.Now())
+	s.Equal([]string{"a", "b"}, recentPacker.PopAllJobs())
+
+	recentPacker.AddJob("a", fake.Now())
+	fake.Advance(90 * time.Second)
+	recentPacker.AddJob("b", fake.Now())
+	s.Equal([]string{"b"}, recentPacker.PopAllJobs())
+}
+
 func TestIRecentJobPackerTestSuite(t *testing.T) {
 	s := new(IRecentJobPackerTestSuite)
 	suite.Run(t, s)
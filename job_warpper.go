@@ -1,12 +1,43 @@
 package dcron
 
-import "github.com/libi/dcron/cron"
+import (
+	"context"
+	"time"
+
+	"github.com/libi/dcron/cron"
+)
 
 // Job Interface
 type Job interface {
 	Run()
 }
 
+// ContextJob is a Job which wants the context of each execution,
+// the fencing token of an execution can be got by FencingToken.
+type ContextJob interface {
+	Job
+	RunWithContext(ctx context.Context)
+}
+
+// ContextFuncJob is a wrapper that turns a func(context.Context) into a ContextJob
+type ContextFuncJob func(ctx context.Context)
+
+func (f ContextFuncJob) Run() { f(context.Background()) }
+
+func (f ContextFuncJob) RunWithContext(ctx context.Context) { f(ctx) }
+
+type fencingTokenKey struct{}
+
+// FencingToken returns the fencing token of the job execution in ctx.
+// The tokens increase monotonically, so the downstream systems can
+// reject the writes from a stale owner of the job. It returns false if
+// the driver does not implement CounterDriver, which the tokens need, or
+// the token overflows, see NodePool.NextFencingToken.
+func FencingToken(ctx context.Context) (uint64, bool) {
+	token, ok := ctx.Value(fencingTokenKey{}).(uint64)
+	return token, ok
+}
+
 // This type of Job will be
 // recovered in a node of service
 // restarting.
@@ -24,18 +55,120 @@ type JobWarpper struct {
 	Name    string
 	CronStr string
 	Job     Job
+
+	// StrictOnce makes the job claim each tick in the cluster before
+	// running, see WithStrictOnce.
+	StrictOnce bool
+	// HomeZone is the zone preferred by the job, see WithHomeZone.
+	HomeZone string
+	// Replicas is the number of nodes owning the job, see WithReplicas.
+	Replicas int
+	// StandbyGrace is the grace period of the standby owners of the job,
+	// see WithHotStandby.
+	StandbyGrace time.Duration
+	// Calendars exclude times from the schedule of the job,
+	// see WithJobCalendar.
+	Calendars []cron.Calendar
+
+	schedule cron.Schedule
+	// the precision of the scheduled times of the job, the ticks
+	// of the job are claimed and reported at this precision.
+	tickPrecision time.Duration
 }
 
 // Run is run job
 func (job JobWarpper) Run() {
+	job.RunScheduled(job.Dcron.clock.Now())
+}
+
+// RunScheduled runs the job for its activation at activation, the tick
+// claimed and reported in the cluster is the activation time, so the
+// nodes agree on it whenever the job is dispatched.
+func (job JobWarpper) RunScheduled(activation time.Time) {
+	tick := job.tick(activation)
 	//如果该任务分配给了这个节点 则允许执行
-	if job.Dcron.allowThisNodeRun(job.Name) {
+	if job.StandbyGrace > 0 {
+		job.Dcron.runWithStandby(job, tick)
+		return
+	}
+	if job.Dcron.allowThisNodeRun(job, tick) {
 		job.Execute()
 	}
 }
 
+// Next returns the next activation time of the job after t,
+// the times excluded by the calendars are skipped.
+func (job *JobWarpper) Next(t time.Time) time.Time {
+	if job.schedule == nil {
+		return time.Time{}
+	}
+	return job.schedule.Next(t)
+}
+
+// Describe returns the human-readable description of CronStr, e.g.
+// "At 05:00 on Monday through Friday", with the hashed fields resolved by
+// the job name. It returns CronStr if the parser of Dcron can not describe
+// it, and "" for the jobs added by AddScheduleJob.
+func (job *JobWarpper) Describe(locale cron.Locale) string {
+	if job.CronStr == "" || job.Dcron == nil {
+		return job.CronStr
+	}
+	describer, ok := job.Dcron.cr.Parser().(cron.DescribingParser)
+	if !ok {
+		return job.CronStr
+	}
+	description, err := describer.DescribeWithKey(job.CronStr, job.Name, locale)
+	if err != nil {
+		return job.CronStr
+	}
+	return description
+}
+
+// tick returns the scheduled time of the job running at t.
+func (job JobWarpper) tick(t time.Time) time.Time {
+	if job.tickPrecision <= 0 {
+		return t.Truncate(time.Second)
+	}
+	return t.Truncate(job.tickPrecision)
+}
+
+// tickPrecision returns the precision of the activation times of schedule.
+// A sub-second schedule activates at the multiples of its delay.
+func tickPrecision(schedule cron.Schedule) time.Duration {
+	if s, ok := schedule.(cron.ConstantDelaySchedule); ok && s.Delay%time.Second != 0 {
+		return s.Delay
+	}
+	return time.Second
+}
+
+// checkOptions returns ErrJobConflict if the options of the job can
+// not be used together. A tick claimed by a strict once job runs on one
+// node, so the job can neither have replicas nor standbys.
+func (job *JobWarpper) checkOptions() error {
+	if job.StrictOnce && (job.StandbyGrace > 0 || job.Replicas > 1) {
+		return ErrJobConflict
+	}
+	return nil
+}
+
+// replicas returns the number of owners of the job.
+func (job JobWarpper) replicas() int {
+	if job.StandbyGrace > 0 && job.Replicas < 2 {
+		return 2
+	}
+	if job.Replicas < 1 {
+		return 1
+	}
+	return job.Replicas
+}
+
 func (job JobWarpper) Execute() {
-	if job.Job != nil {
-		job.Job.Run()
+	if job.Job == nil {
+		return
+	}
+	if contextJob, ok := job.Job.(ContextJob); ok {
+		contextJob.RunWithContext(job.Dcron.executionContext())
+		return
 	}
+	job.Job.Run()
 }
@@ -2,21 +2,27 @@ package dcron_test
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
+	"time"
 
 	"github.com/dcron-contrib/commons"
+	"github.com/libi/dcron/dcrontest"
 )
 
 // This is a mock driver used for unit test.
 
 type MockDriver struct {
+	ID           string
 	StartFunc    func(context.Context) error
 	GetNodesFunc func(context.Context) ([]string, error)
+	StopFunc     func(context.Context) error
 }
 
 func (md *MockDriver) Init(serviceName string, opts ...commons.Option) {}
 
 func (md *MockDriver) NodeID() string {
-	return ""
+	return md.ID
 }
 
 func (md *MockDriver) GetNodes(ctx context.Context) (nodes []string, err error) {
@@ -34,9 +40,49 @@ func (md *MockDriver) Start(ctx context.Context) (err error) {
 }
 
 func (md *MockDriver) Stop(ctx context.Context) (err error) {
+	if md.StopFunc != nil {
+		return md.StopFunc(ctx)
+	}
 	return
 }
 
 func (md *MockDriver) WithOption(opt commons.Option) (err error) {
 	return
 }
+
+// CountingKVDriver is a dcrontest.MemoryDriver counting the reads of
+// key-value, which fail if Fail is set.
+type CountingKVDriver struct {
+	*dcrontest.MemoryDriver
+	Reads atomic.Int32
+	Fail  atomic.Bool
+}
+
+func (md *CountingKVDriver) GetKV(ctx context.Context, key string) (string, bool, error) {
+	md.Reads.Add(1)
+	if md.Fail.Load() {
+		return "", false, errors.New("get kv failed")
+	}
+	return md.MemoryDriver.GetKV(ctx, key)
+}
+
+// SplitClaimDriver is a dcrontest.MemoryDriver claiming the ticks in the
+// store of Claims, so the nodes in different stores claim in one store.
+type SplitClaimDriver struct {
+	*dcrontest.MemoryDriver
+	Claims *dcrontest.MemoryDriver
+}
+
+func (md *SplitClaimDriver) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return md.Claims.SetNX(ctx, key, value, ttl)
+}
+
+// EpochDriver is a dcrontest.MemoryDriver whose counter always returns Epoch.
+type EpochDriver struct {
+	*dcrontest.MemoryDriver
+	Epoch int64
+}
+
+func (md *EpochDriver) Incr(ctx context.Context, key string) (int64, error) {
+	return md.Epoch, nil
+}
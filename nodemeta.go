@@ -0,0 +1,166 @@
+package dcron
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// the metadata of a node is kept for this many update rounds
+// after it is published.
+const metaTTLRounds = 10
+
+// Role is the role of a node in the cluster.
+type Role string
+
+const (
+	// RoleWorker nodes are assigned jobs by the hash ring.
+	RoleWorker Role = "worker"
+	// RoleObserver nodes register themselves and see the hash ring,
+	// but are never assigned jobs.
+	RoleObserver Role = "observer"
+)
+
+// nodeMeta is the metadata of a node, which is published
+// to the other nodes through KVDriver.
+type nodeMeta struct {
+	role Role
+	zone string
+}
+
+func (meta nodeMeta) encode() string {
+	values := url.Values{}
+	values.Set("role", string(meta.role))
+	if meta.zone != "" {
+		values.Set("zone", meta.zone)
+	}
+	return values.Encode()
+}
+
+func decodeNodeMeta(s string) (nodeMeta, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nodeMeta{}, err
+	}
+	meta := nodeMeta{
+		role: Role(values.Get("role")),
+		zone: values.Get("zone"),
+	}
+	if meta.role == "" {
+		meta.role = RoleWorker
+	}
+	return meta, nil
+}
+
+// hasMeta reports whether this node has metadata to publish,
+// a worker without zone has none.
+func (np *NodePool) hasMeta() bool {
+	return np.role != RoleWorker || np.zone != ""
+}
+
+func (np *NodePool) metaKey(nodeID string) string {
+	return metaKey(np.serviceName, "node", nodeID)
+}
+
+// peerMeta is the metadata of a peer node cached by this node. The
+// metadata of a node does not change while it runs, so it is cached once
+// found. The missing metadata is read in every round for metaTTLRounds
+// rounds, while the node may not have published it yet, and once in
+// metaTTLRounds rounds after that.
+type peerMeta struct {
+	meta   nodeMeta
+	found  bool
+	rounds int // the rounds the metadata has been missing
+}
+
+// getNodes returns the nodes which can be assigned jobs, and the zones
+// of them. Observers are left out if the driver supports key-value,
+// otherwise there is no observer and zone in the cluster. It fails if
+// the metadata of a node can not be read, so the rings of the nodes
+// never differ by a guessed role.
+func (np *NodePool) getNodes(ctx context.Context) ([]string, map[string]string, error) {
+	nodes, err := np.driver.GetNodes(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	kv, ok := np.driver.(KVDriver)
+	if !ok {
+		return nodes, nil, nil
+	}
+	self := nodeMeta{role: np.role, zone: np.zone}
+	if np.hasMeta() {
+		// refresh the metadata before it expires.
+		err = kv.SetKV(ctx, np.metaKey(np.nodeID), self.encode(), metaTTLRounds*np.updateDuration)
+		if err != nil {
+			np.logger.Errorf("publish node metadata error %v", err)
+		}
+	}
+	np.peerMetasMut.Lock()
+	defer np.peerMetasMut.Unlock()
+	workers := make([]string, 0, len(nodes))
+	zones := make(map[string]string)
+	alive := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		alive[node] = true
+		meta := self
+		if node != np.nodeID {
+			meta, err = np.getPeerMeta(ctx, kv, node)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if meta.role == RoleObserver {
+			continue
+		}
+		workers = append(workers, node)
+		if meta.zone != "" {
+			zones[node] = meta.zone
+		}
+	}
+	for node := range np.peerMetas {
+		if !alive[node] {
+			delete(np.peerMetas, node)
+		}
+	}
+	return workers, zones, nil
+}
+
+// getPeerMeta returns the metadata of a peer node, a peer without
+// metadata is a worker without zone. It must be called with peerMetasMut held.
+func (np *NodePool) getPeerMeta(ctx context.Context, kv KVDriver, nodeID string) (nodeMeta, error) {
+	cached, ok := np.peerMetas[nodeID]
+	if !ok {
+		cached = &peerMeta{}
+		np.peerMetas[nodeID] = cached
+	}
+	if cached.found {
+		return cached.meta, nil
+	}
+	cached.rounds++
+	if cached.rounds > metaTTLRounds && cached.rounds%metaTTLRounds != 0 {
+		return nodeMeta{role: RoleWorker}, nil
+	}
+	meta, found, err := np.getNodeMeta(ctx, kv, nodeID)
+	if err != nil {
+		return nodeMeta{}, fmt.Errorf("get metadata of node %s: %w", nodeID, err)
+	}
+	cached.meta, cached.found = meta, found
+	return meta, nil
+}
+
+func (np *NodePool) getNodeMeta(ctx context.Context, kv KVDriver, nodeID string) (nodeMeta, bool, error) {
+	value, ok, err := kv.GetKV(ctx, np.metaKey(nodeID))
+	if err != nil {
+		return nodeMeta{}, false, err
+	}
+	if !ok {
+		return nodeMeta{role: RoleWorker}, false, nil
+	}
+	meta, err := decodeNodeMeta(value)
+	if err != nil {
+		// read again like the missing metadata.
+		np.logger.Errorf("decode metadata of node %s error %v", nodeID, err)
+		return nodeMeta{role: RoleWorker}, false, nil
+	}
+	return meta, true, nil
+}
@@ -3,24 +3,44 @@ package dcron
 import (
 	"context"
 	"log"
+	"math"
 	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/dcron-contrib/commons"
 	"github.com/dcron-contrib/commons/dlog"
+	"github.com/libi/dcron/clock"
 	"github.com/libi/dcron/consistenthash"
 )
 
 const (
-	NodePoolStateSteady  = "NodePoolStateSteady"
-	NodePoolStateUpgrade = "NodePoolStateUpgrade"
+	NodePoolStateSteady   = "NodePoolStateSteady"
+	NodePoolStateUpgrade  = "NodePoolStateUpgrade"
+	NodePoolStateNoQuorum = "NodePoolStateNoQuorum"
+	NodePoolStateDraining = "NodePoolStateDraining"
+
+	// the recent maximum of visible nodes is taken over
+	// this many update rounds by default.
+	quorumWindowRounds = 10
+	// without quorum, the healthy node counts are kept for this many
+	// quorum windows, after which the smaller view is taken as the
+	// cluster, e.g. after the cluster has been scaled down.
+	quorumLockoutWindows = 10
+
+	// a claim of a tick is kept for this duration, which should
+	// be longer than the time differences between nodes.
+	claimTTL = time.Minute
 )
 
+// NodePoolOption is NodePool Option
+type NodePoolOption func(*NodePool)
+
 // NodePool
 // For cluster steable.
-// NodePool has 2 states:
+// NodePool has 4 states:
 //  1. Steady
 //     If this nodePoolLists is the same as the last update,
 //     we will mark this node's state to Steady. In this state,
@@ -29,6 +49,15 @@ const (
 //     If this nodePoolLists is different to the last update,
 //     we will mark this node's state to Upgrade. In this state,
 //     this node can not run jobs.
+//  3. NoQuorum
+//     If a minimum quorum is configured and this node sees fewer
+//     nodes than required, we will mark this node's state to NoQuorum.
+//     In this state, this node can not run jobs.
+//  4. Draining
+//     If this node is leaving the cluster by Drain, we will mark
+//     this node's state to Draining. In this state, this node runs
+//     the jobs it owns in its last steady ring until the other nodes
+//     have taken over, and can not run jobs after that.
 type NodePool struct {
 	serviceName string
 	nodeID      string
@@ -36,17 +65,53 @@ type NodePool struct {
 	rwMut sync.RWMutex
 	nodes *consistenthash.Map
 
+	ringVersion uint64
+	epoch       uint64
+	epochSeq    uint64
+	epochStale  bool // the hash ring changed after the epoch advanced
+
 	driver         commons.DriverV2
 	hashReplicas   int
 	hashFn         consistenthash.Hash
 	updateDuration time.Duration
+	clock          clock.Clock
 
 	logger   dlog.Logger
 	stopChan chan int
-	preNodes []string // sorted
+	waitDone chan struct{} // closed when waitingForHashRing returns
+	preNodes []string      // sorted
 
 	lastUpdateNodesTime atomic.Value
 	state               atomic.Value
+
+	role      Role
+	zone      string
+	nodeZones map[string]string              // nodeID -> zone
+	zoneRings map[string]*consistenthash.Map // zone -> ring of the nodes in zone
+
+	peerMetasMut sync.Mutex
+	peerMetas    map[string]*peerMeta // nodeID -> metadata
+
+	stableRounds       int // equal rounds needed before Steady
+	minUpgradeDuration time.Duration
+	equalRounds        int
+	flapCount          int
+
+	minQuorumRatio float64
+	minQuorumNodes int
+	quorumWindow   time.Duration     // zero means quorumWindowRounds rounds
+	nodeCounts     []nodeCountSample // for the recent maximum of visible nodes
+
+	drainFrom string // the state before Drain, until the node is drained
+
+	ringAgreement bool   // Steady only when all nodes have the same ring
+	ringAgreed    bool   // all nodes have the same ring as this node
+	ringMismatch  string // the nodes having a different ring
+}
+
+type nodeCountSample struct {
+	count int
+	at    time.Time
 }
 
 func NewNodePool(
@@ -55,20 +120,28 @@ func NewNodePool(
 	updateDuration time.Duration,
 	hashReplicas int,
 	logger dlog.Logger,
+	opts ...NodePoolOption,
 ) INodePool {
 	np := &NodePool{
 		serviceName:    serviceName,
 		driver:         drv,
 		hashReplicas:   hashReplicas,
 		updateDuration: updateDuration,
+		clock:          clock.Real(),
+		role:           RoleWorker,
+		stableRounds:   1,
 		logger: &dlog.StdLogger{
 			Log: log.Default(),
 		},
-		stopChan: make(chan int, 1),
+		stopChan:  make(chan int, 1),
+		peerMetas: make(map[string]*peerMeta),
 	}
 	if logger != nil {
 		np.logger = logger
 	}
+	for _, opt := range opts {
+		opt(np)
+	}
 	np.driver.Init(serviceName,
 		commons.NewTimeoutOption(updateDuration),
 		commons.NewLoggerOption(np.logger))
@@ -80,24 +153,48 @@ func (np *NodePool) SetLogger(logger dlog.Logger) {
 }
 
 func (np *NodePool) Start(ctx context.Context) (err error) {
-	err = np.driver.Start(ctx)
+	if _, ok := np.driver.(KVDriver); !ok && (np.hasMeta() || np.ringAgreement) {
+		np.logger.Errorf("start pool error: observer role, zone and ring agreement need a driver with key-value support")
+		return ErrDriverNotSupportKV
+	}
+	// ctx only bounds the start, the driver keeps the registration of
+	// this node, e.g. the keepalive of a lease, alive until Stop.
+	err = np.driver.Start(context.Background())
 	if err != nil {
 		np.logger.Errorf("start pool error: %v", err)
 		return
 	}
 	np.nodeID = np.driver.NodeID()
-	nowNodes, err := np.driver.GetNodes(ctx)
+	nowNodes, nowZones, err := np.getNodes(ctx)
 	if err != nil {
 		np.logger.Errorf("get nodes error: %v", err)
 		return
 	}
 	np.state.Store(NodePoolStateUpgrade)
-	np.updateHashRing(nowNodes)
-	go np.waitingForHashRing()
+	np.updateHashRing(nowNodes, nowZones)
+	np.advanceEpoch(ctx)
+	np.checkRingAgreement(ctx)
+	// a new stop channel for each start, so a stop sent to the
+	// previous run, e.g. by Drain then Stop, is not taken by this one.
+	stop, done := make(chan int, 1), make(chan struct{})
+	np.rwMut.Lock()
+	np.stopChan, np.waitDone = stop, done
+	np.rwMut.Unlock()
+	go np.waitingForHashRing(stop, done)
 
 	// stuck util the cluster state came to steady.
 	for np.getState() != NodePoolStateSteady {
-		<-time.After(np.updateDuration)
+		timer := np.clock.NewTimer(np.updateDuration)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+			np.logger.Errorf("waiting for steady error: %v", err)
+			np.stopWaiting(context.Background())
+			np.driver.Stop(context.Background())
+			return
+		}
 	}
 	np.logger.Infof("nodepool started for serve, nodeID=%s", np.nodeID)
 
@@ -106,6 +203,13 @@ func (np *NodePool) Start(ctx context.Context) (err error) {
 
 // Check if this job can be run in this node.
 func (np *NodePool) CheckJobAvailable(jobName string) (bool, error) {
+	return np.CheckJobAvailableInZone(jobName, "")
+}
+
+// Check if this job, which prefers the nodes in homeZone, can be run in this node.
+// The owner is resolved on the ring of the nodes in homeZone first,
+// and on the ring of all nodes if there is no node alive in homeZone.
+func (np *NodePool) CheckJobAvailableInZone(jobName, homeZone string) (bool, error) {
 	np.rwMut.RLock()
 	defer np.rwMut.RUnlock()
 	if np.nodes == nil {
@@ -115,10 +219,10 @@ func (np *NodePool) CheckJobAvailable(jobName string) (bool, error) {
 	if np.nodes.IsEmpty() {
 		return false, nil
 	}
-	if np.state.Load().(string) != NodePoolStateSteady {
-		return false, ErrNodePoolIsUpgrading
+	if err := np.stateErr(); err != nil {
+		return false, err
 	}
-	targetNode := np.nodes.Get(jobName)
+	targetNode := ownerOf(np.nodes, np.zoneRings, jobName, homeZone)
 	if np.nodeID == targetNode {
 		np.logger.Infof("job %s, running in node: %s, nodeID is %s", jobName, targetNode, np.nodeID)
 	}
@@ -126,71 +230,524 @@ func (np *NodePool) CheckJobAvailable(jobName string) (bool, error) {
 	return np.nodeID == targetNode, nil
 }
 
+// CheckJobReplica returns the rank of this node in the first `replicas`
+// owners of the job, which prefers the nodes in homeZone. The rank of
+// the primary owner is 0, and the rank is -1 if this node is not an owner.
+func (np *NodePool) CheckJobReplica(jobName, homeZone string, replicas int) (int, error) {
+	np.rwMut.RLock()
+	defer np.rwMut.RUnlock()
+	if np.nodes == nil {
+		np.logger.Errorf("nodeID=%s, NodePool.nodes is nil", np.nodeID)
+		return -1, ErrNodePoolIsNil
+	}
+	if np.nodes.IsEmpty() {
+		return -1, nil
+	}
+	if err := np.stateErr(); err != nil {
+		return -1, err
+	}
+	for rank, owner := range ownersOf(np.nodes, np.zoneRings, jobName, homeZone, replicas) {
+		if owner == np.nodeID {
+			return rank, nil
+		}
+	}
+	return -1, nil
+}
+
+// stateErr returns the error why the jobs can not be run
+// in the current state, it must be called with the lock held.
+func (np *NodePool) stateErr() error {
+	switch np.getState() {
+	case NodePoolStateSteady:
+		return nil
+	case NodePoolStateNoQuorum:
+		return ErrNodePoolNoQuorum
+	case NodePoolStateDraining:
+		if np.drainFrom == NodePoolStateSteady {
+			// the other nodes have not taken over the jobs yet.
+			return nil
+		}
+		return ErrNodePoolIsDraining
+	default:
+		return ErrNodePoolIsUpgrading
+	}
+}
+
 func (np *NodePool) Stop(ctx context.Context) error {
-	np.stopChan <- 1
+	np.stopWaiting(ctx)
 	np.driver.Stop(ctx)
+	np.rwMut.Lock()
 	np.preNodes = make([]string, 0)
+	np.nodeCounts = nil
+	np.rwMut.Unlock()
 	return nil
 }
 
+// Drain deregisters this node from the driver, so the other nodes will
+// take over its jobs, and waits until the other nodes have reached a
+// steady ring without this node. Until then, this node keeps running the
+// jobs it owns in its last ring if that ring was steady, so no tick is lost
+// before the other nodes take over.
+func (np *NodePool) Drain(ctx context.Context) error {
+	np.stopWaiting(ctx)
+	np.rwMut.Lock()
+	np.drainFrom = np.getState()
+	np.state.Store(NodePoolStateDraining)
+	np.rwMut.Unlock()
+	defer func() {
+		np.rwMut.Lock()
+		np.drainFrom = ""
+		np.rwMut.Unlock()
+	}()
+	if err := np.driver.Stop(ctx); err != nil {
+		np.logger.Errorf("deregister node error: %v", err)
+		return err
+	}
+	np.logger.Infof("nodepool draining, nodeID=%s", np.nodeID)
+
+	tick := np.clock.NewTicker(np.updateDuration)
+	defer tick.Stop()
+	var (
+		preNodes    []string
+		gone        bool // this node is not in preNodes
+		changedAt   time.Time
+		equalRounds int
+	)
+	for {
+		select {
+		case <-tick.C():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		nodes, err := np.driver.GetNodes(ctx)
+		if err != nil {
+			np.logger.Errorf("get nodes error %v", err)
+			continue
+		}
+		sort.Strings(nodes)
+		// the peers will come to steady after seeing the same nodes
+		// without this node in the stable rounds, one more round is
+		// waited for the peers updating out of phase with this node.
+		if gone && equalNodes(preNodes, nodes) {
+			equalRounds++
+			if equalRounds > np.stableRounds && clock.Since(np.clock, changedAt) >= np.minUpgradeDuration {
+				np.logger.Infof("nodepool drained, nodes=%v", nodes)
+				return nil
+			}
+			continue
+		}
+		preNodes, changedAt, equalRounds = nodes, np.clock.Now(), 0
+		gone = !containsNode(nodes, np.nodeID)
+	}
+}
+
 func (np *NodePool) GetNodeID() string {
 	return np.nodeID
 }
 
 func (np *NodePool) GetLastNodesUpdateTime() time.Time {
-	return np.lastUpdateNodesTime.Load().(time.Time)
+	// zero until the nodes are first seen, e.g. by an observer
+	// starting before any worker.
+	t, _ := np.lastUpdateNodesTime.Load().(time.Time)
+	return t
+}
+
+func (np *NodePool) GetStatus() NodePoolStatus {
+	np.rwMut.RLock()
+	defer np.rwMut.RUnlock()
+	status := NodePoolStatus{
+		NodeID:         np.nodeID,
+		Role:           np.role,
+		Zone:           np.zone,
+		Nodes:          len(np.preNodes),
+		RecentMaxNodes: np.recentMaxNodes(),
+		FlapCount:      np.flapCount,
+		Epoch:          np.epoch,
+		RingMismatch:   np.ringMismatch,
+	}
+	if state, ok := np.state.Load().(string); ok {
+		status.State = state
+	}
+	if t, ok := np.lastUpdateNodesTime.Load().(time.Time); ok {
+		status.LastNodesUpdateTime = t
+	}
+	return status
+}
+
+func (np *NodePool) GetRingSnapshot() (*RingSnapshot, error) {
+	np.rwMut.RLock()
+	defer np.rwMut.RUnlock()
+	if np.nodes == nil {
+		return nil, ErrNodePoolIsNil
+	}
+	nodes := make([]string, len(np.preNodes))
+	copy(nodes, np.preNodes)
+	zones := make(map[string]string, len(np.nodeZones))
+	for node, zone := range np.nodeZones {
+		zones[node] = zone
+	}
+	return &RingSnapshot{
+		Version:   np.ringVersion,
+		UpdatedAt: np.GetLastNodesUpdateTime(),
+		Nodes:     nodes,
+		Zones:     zones,
+		ring:      np.nodes,
+		zoneRings: np.zoneRings,
+	}, nil
+}
+
+// NextFencingToken returns a token for a job execution in this node.
+// The token is made of the ring epoch in the high 32 bits and a sequence
+// in the epoch in the low 32 bits, so it increases monotonically.
+// The owner in a newer ring always gets greater tokens. It returns
+// ErrDriverNotSupportCounter if the driver does not implement CounterDriver,
+// the epoch can not be shared by the nodes without it, and
+// ErrFencingTokenOverflow if the epoch or the sequence does not fit in
+// 32 bits. The sequence overflowing advances the epoch in the next round.
+func (np *NodePool) NextFencingToken() (uint64, error) {
+	if _, ok := np.driver.(CounterDriver); !ok {
+		return 0, ErrDriverNotSupportCounter
+	}
+	np.rwMut.Lock()
+	defer np.rwMut.Unlock()
+	if np.nodes == nil {
+		return 0, ErrNodePoolIsNil
+	}
+	if np.epoch > math.MaxUint32 {
+		return 0, ErrFencingTokenOverflow
+	}
+	if np.epochSeq >= math.MaxUint32 {
+		np.epochStale = true
+		return 0, ErrFencingTokenOverflow
+	}
+	np.epochSeq++
+	return np.epoch<<32 | np.epochSeq, nil
+}
+
+// ClaimJob claims the execution of the job scheduled at scheduledTime,
+// only one node in the cluster claims it successfully.
+func (np *NodePool) ClaimJob(ctx context.Context, jobName string, scheduledTime time.Time) (bool, error) {
+	claimer, ok := np.driver.(ClaimDriver)
+	if !ok {
+		return false, ErrDriverNotSupportClaim
+	}
+	key := metaKey(np.serviceName, "claim", jobName, strconv.FormatInt(scheduledTime.UnixNano(), 10))
+	return claimer.SetNX(ctx, key, np.nodeID, claimTTL)
+}
+
+// ReportJobDone records that the job scheduled at scheduledTime is done,
+// so the standby owners of the job will not run it.
+func (np *NodePool) ReportJobDone(ctx context.Context, jobName string, scheduledTime time.Time) error {
+	kv, ok := np.driver.(KVDriver)
+	if !ok {
+		return ErrDriverNotSupportKV
+	}
+	return kv.SetKV(ctx, doneKey(np.serviceName, jobName, scheduledTime), np.nodeID, claimTTL)
+}
+
+// IsJobDone reports whether an owner of the job has reported that
+// the job scheduled at scheduledTime is done.
+func (np *NodePool) IsJobDone(ctx context.Context, jobName string, scheduledTime time.Time) (bool, error) {
+	kv, ok := np.driver.(KVDriver)
+	if !ok {
+		return false, ErrDriverNotSupportKV
+	}
+	_, done, err := kv.GetKV(ctx, doneKey(np.serviceName, jobName, scheduledTime))
+	return done, err
+}
+
+func doneKey(serviceName, jobName string, scheduledTime time.Time) string {
+	return metaKey(serviceName, "done", jobName, strconv.FormatInt(scheduledTime.UnixNano(), 10))
+}
+
+// advanceEpoch moves the ring epoch forward after the hash ring changed.
+// The epoch is a counter shared by the cluster in the driver implementing
+// CounterDriver, and every node increases it once on each change of its
+// ring, so the epoch grows by the number of nodes in a change of the
+// cluster. There is no epoch without the counter.
+func (np *NodePool) advanceEpoch(ctx context.Context) {
+	np.rwMut.RLock()
+	stale, version := np.epochStale, np.ringVersion
+	np.rwMut.RUnlock()
+	if !stale {
+		return
+	}
+	var epoch uint64
+	if counter, ok := np.driver.(CounterDriver); ok {
+		n, err := counter.Incr(ctx, metaKey(np.serviceName, "epoch"))
+		if err != nil {
+			np.logger.Errorf("advance epoch error %v", err)
+			return
+		}
+		epoch = uint64(n)
+	}
+	np.rwMut.Lock()
+	defer np.rwMut.Unlock()
+	if np.ringVersion != version {
+		// the hash ring changed again, advance in the next round.
+		return
+	}
+	np.epoch, np.epochSeq, np.epochStale = epoch, 0, false
+	if epoch > 0 {
+		np.logger.Infof("ring epoch advanced to %d", epoch)
+	}
 }
 
 func (np *NodePool) getState() string {
 	return np.state.Load().(string)
 }
 
-func (np *NodePool) waitingForHashRing() {
-	tick := time.NewTicker(np.updateDuration)
+// stopWaiting stops the goroutine waiting for the hash ring, and waits
+// until it returns or ctx is done. The stop is never blocked, so the node
+// pool may be stopped more than once.
+func (np *NodePool) stopWaiting(ctx context.Context) {
+	np.rwMut.RLock()
+	stop, done := np.stopChan, np.waitDone
+	np.rwMut.RUnlock()
+	select {
+	case stop <- 1:
+	default:
+	}
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func (np *NodePool) waitingForHashRing(stop <-chan int, done chan<- struct{}) {
+	defer close(done)
+	tick := np.clock.NewTicker(np.updateDuration)
+	defer tick.Stop()
 	for {
 		select {
-		case <-tick.C:
-			nowNodes, err := np.driver.GetNodes(context.Background())
+		case <-tick.C():
+			nowNodes, nowZones, err := np.getNodes(context.Background())
 			if err != nil {
 				np.logger.Errorf("get nodes error %v", err)
 				continue
 			}
-			np.updateHashRing(nowNodes)
-		case <-np.stopChan:
+			np.updateHashRing(nowNodes, nowZones)
+			np.advanceEpoch(context.Background())
+			np.checkRingAgreement(context.Background())
+		case <-stop:
 			return
 		}
 	}
 }
 
-func (np *NodePool) updateHashRing(nodes []string) {
+func (np *NodePool) updateHashRing(nodes []string, zones map[string]string) {
 	np.rwMut.Lock()
 	defer np.rwMut.Unlock()
-	if np.equalRing(nodes) {
+	quorum := np.hasQuorum(len(nodes))
+	np.recordNodeCount(len(nodes), quorum)
+	if np.equalRing(nodes) && equalZones(zones, np.nodeZones) {
+		np.equalRounds++
+		if np.epochStale {
+			np.logger.Infof("waiting for epoch, nowNodes=%v", nodes)
+			return
+		}
+		if np.ringAgreement && !np.ringAgreed {
+			np.logger.Infof("waiting for ring agreement, nowNodes=%v, mismatch=%s", nodes, np.ringMismatch)
+			return
+		}
+		if np.equalRounds < np.stableRounds ||
+			clock.Since(np.clock, np.GetLastNodesUpdateTime()) < np.minUpgradeDuration {
+			np.logger.Infof("waiting for stable, nowNodes=%v, equalRounds=%d", nodes, np.equalRounds)
+			return
+		}
+		if !quorum {
+			np.state.Store(NodePoolStateNoQuorum)
+			np.logger.Warnf("no quorum, nowNodes=%v, recentMaxNodes=%d", nodes, np.recentMaxNodes())
+			return
+		}
 		np.state.Store(NodePoolStateSteady)
 		np.logger.Infof("nowNodes=%v, preNodes=%v", nodes, np.preNodes)
 		return
 	}
-	np.lastUpdateNodesTime.Store(time.Now())
+	if np.nodes != nil && np.getState() == NodePoolStateUpgrade {
+		// the nodes changed again before the ring came to steady.
+		np.flapCount++
+	}
+	np.equalRounds = 0
+	np.ringVersion++
+	np.epochStale = true
+	np.ringAgreed = false
+	np.lastUpdateNodesTime.Store(np.clock.Now())
 	np.state.Store(NodePoolStateUpgrade)
-	np.logger.Infof("update hashRing nodes=%+v", nodes)
+	np.logger.Infof("update hashRing nodes=%+v, zones=%+v", nodes, zones)
 	np.preNodes = make([]string, len(nodes))
 	copy(np.preNodes, nodes)
-	np.nodes = consistenthash.New(np.hashReplicas, np.hashFn)
-	for _, v := range nodes {
-		np.nodes.Add(v)
+	sort.Strings(np.preNodes)
+	np.nodeZones = zones
+	// the rings are updated from copies, so the snapshots
+	// taken before keep unchanged.
+	np.nodes = np.updateRing(np.nodes, np.preNodes)
+	zoneNodes := make(map[string][]string)
+	for _, v := range np.preNodes {
+		if zone, ok := zones[v]; ok {
+			zoneNodes[zone] = append(zoneNodes[zone], v)
+		}
+	}
+	zoneRings := make(map[string]*consistenthash.Map, len(zoneNodes))
+	for zone, members := range zoneNodes {
+		zoneRings[zone] = np.updateRing(np.zoneRings[zone], members)
+	}
+	np.zoneRings = zoneRings
+}
+
+// updateRing returns a ring of the sorted members. If ring is not nil,
+// the returned ring is a copy of it with the changed members only.
+func (np *NodePool) updateRing(ring *consistenthash.Map, members []string) *consistenthash.Map {
+	if ring == nil {
+		ring = consistenthash.New(np.hashReplicas, np.hashFn)
+		ring.Add(members...)
+		return ring
 	}
+	ring = ring.Clone()
+	added, removed := diffNodes(ring.Members(), members)
+	ring.Remove(removed...)
+	ring.Add(added...)
+	return ring
 }
 
 func (np *NodePool) equalRing(a []string) bool {
 	if len(a) == len(np.preNodes) {
-		la := len(a)
 		sort.Strings(a)
-		for i := 0; i < la; i++ {
-			if a[i] != np.preNodes[i] {
-				return false
-			}
+		return equalNodes(a, np.preNodes)
+	}
+	return false
+}
+
+// equalNodes reports whether the sorted node lists a and b are the same.
+func equalNodes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffNodes returns the nodes only in the sorted list b and
+// the nodes only in the sorted list a.
+func diffNodes(a, b []string) (added, removed []string) {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case a[i] < b[j]:
+			removed = append(removed, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	removed = append(removed, a[i:]...)
+	added = append(added, b[j:]...)
+	return
+}
+
+// equalZones reports whether the zones of nodes a and b are the same.
+func equalZones(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for node, zone := range a {
+		if bzone, ok := b[node]; !ok || bzone != zone {
+			return false
+		}
+	}
+	return true
+}
+
+// ownerOf returns the owner of the job, which is resolved on the ring
+// of homeZone first, and on the global ring if homeZone has no node.
+func ownerOf(ring *consistenthash.Map, zoneRings map[string]*consistenthash.Map, jobName, homeZone string) string {
+	if zoneRing, ok := zoneRings[homeZone]; ok && homeZone != "" && !zoneRing.IsEmpty() {
+		return zoneRing.Get(jobName)
+	}
+	return ring.Get(jobName)
+}
+
+// ownersOf returns the first n owners of the job. The owners are resolved
+// on the ring of homeZone first, and filled up on the global ring if
+// homeZone has less than n nodes.
+func ownersOf(ring *consistenthash.Map, zoneRings map[string]*consistenthash.Map, jobName, homeZone string, n int) []string {
+	zoneRing, ok := zoneRings[homeZone]
+	if !ok || homeZone == "" || zoneRing.IsEmpty() {
+		return ring.GetN(jobName, n)
+	}
+	owners := zoneRing.GetN(jobName, n)
+	for _, node := range ring.GetN(jobName, n+len(owners)) {
+		if len(owners) >= n {
+			break
+		}
+		if !containsNode(owners, node) {
+			owners = append(owners, node)
+		}
+	}
+	return owners
+}
+
+func containsNode(nodes []string, nodeID string) bool {
+	for _, node := range nodes {
+		if node == nodeID {
+			return true
 		}
-		return true
 	}
 	return false
 }
+
+// recordNodeCount keeps the visible node counts inside the quorum window,
+// it must be called with the write lock held. The counts without quorum
+// are not recorded, so a partitioned node keeps comparing itself to the
+// last healthy view, but only for quorumLockoutWindows windows, so a
+// cluster scaled down below the ratio comes back to quorum after it.
+func (np *NodePool) recordNodeCount(count int, quorum bool) {
+	if np.minQuorumRatio <= 0 && np.minQuorumNodes <= 0 {
+		return
+	}
+	window := np.quorumWindow
+	if window <= 0 {
+		window = quorumWindowRounds * np.updateDuration
+	}
+	if !quorum {
+		window *= quorumLockoutWindows
+	}
+	now := np.clock.Now()
+	expired := now.Add(-window)
+	i := 0
+	for i < len(np.nodeCounts) && np.nodeCounts[i].at.Before(expired) {
+		i++
+	}
+	np.nodeCounts = np.nodeCounts[i:]
+	if quorum {
+		np.nodeCounts = append(np.nodeCounts, nodeCountSample{count: count, at: now})
+	}
+}
+
+func (np *NodePool) recentMaxNodes() (max int) {
+	for _, sample := range np.nodeCounts {
+		if sample.count > max {
+			max = sample.count
+		}
+	}
+	return
+}
+
+// hasQuorum reports whether count visible nodes are enough to run jobs.
+func (np *NodePool) hasQuorum(count int) bool {
+	if count < np.minQuorumNodes {
+		return false
+	}
+	return float64(count) >= np.minQuorumRatio*float64(np.recentMaxNodes())
+}
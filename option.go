@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/dcron-contrib/commons/dlog"
+	"github.com/libi/dcron/clock"
+	"github.com/libi/dcron/consistenthash"
 	"github.com/libi/dcron/cron"
 )
 
@@ -34,6 +36,51 @@ func WithHashReplicas(d int) Option {
 	}
 }
 
+// WithHashFunc set the hash function of the hash ring, the default is
+// consistenthash.CRC32. All nodes of a cluster must use the same hash
+// function, otherwise they disagree on the owners of jobs.
+func WithHashFunc(fn consistenthash.Hash) Option {
+	return func(d *Dcron) {
+		d.nodePoolOptions = append(d.nodePoolOptions, func(np *NodePool) {
+			np.hashFn = fn
+		})
+	}
+}
+
+// WithRingAgreement makes this node come to steady only when all nodes
+// in the hash ring agree on it. Each node publishes a fingerprint of
+// its hash ring, made of the nodes, the hash replicas and the hash
+// function, and the nodes disagreeing are reported in Status. All nodes
+// of a cluster must enable it, and the driver must implement KVDriver.
+func WithRingAgreement() Option {
+	return func(d *Dcron) {
+		d.nodePoolOptions = append(d.nodePoolOptions, func(np *NodePool) {
+			np.ringAgreement = true
+		})
+	}
+}
+
+// WithCalendar makes all jobs skip the times excluded by calendar,
+// e.g. holidays. It can be used more than once.
+func WithCalendar(calendar cron.Calendar) Option {
+	return func(d *Dcron) {
+		d.calendars = append(d.calendars, calendar)
+	}
+}
+
+// WithClock uses the provided clock instead of the system time in the
+// scheduler and the node pool, e.g. a fake clock of package clocktest
+// to test the schedules and the failover without sleeping.
+func WithClock(c clock.Clock) Option {
+	return func(d *Dcron) {
+		d.clock = c
+		d.crOptions = append(d.crOptions, cron.WithClock(c))
+		d.nodePoolOptions = append(d.nodePoolOptions, func(np *NodePool) {
+			np.clock = c
+		})
+	}
+}
+
 // CronOptionLocation is warp cron with location
 func CronOptionLocation(loc *time.Location) Option {
 	return func(dcron *Dcron) {
@@ -66,6 +113,30 @@ func CronOptionChain(wrappers ...cron.JobWrapper) Option {
 	}
 }
 
+// WithClockJumpPolicy sets how the jobs are run after the wall clock jumps
+// by at least threshold, e.g. stepped by NTP or the VM paused, see
+// cron.WithClockJumpPolicy. Each run of cron.RunAll claims the tick of its
+// own missed activation, so a job of WithStrictOnce runs once for each.
+func WithClockJumpPolicy(policy cron.ClockJumpPolicy, threshold time.Duration) Option {
+	return func(dcron *Dcron) {
+		f := cron.WithClockJumpPolicy(policy, threshold)
+		dcron.crOptions = append(dcron.crOptions, f)
+	}
+}
+
+// WithClockJumpHandler calls handler with the name of each job affected by
+// a jump of the wall clock, e.g. to report the missed runs.
+func WithClockJumpHandler(handler func(jobName string, jump cron.ClockJump)) Option {
+	return func(dcron *Dcron) {
+		f := cron.WithClockJumpHandler(func(jump cron.ClockJump) {
+			if job, ok := jump.Job.(*JobWarpper); ok {
+				handler(job.Name, jump)
+			}
+		})
+		dcron.crOptions = append(dcron.crOptions, f)
+	}
+}
+
 // You can defined yourself recover function to make the
 // job will be added to your dcron when the process restart
 func WithRecoverFunc(recoverFunc RecoverFuncType) Option {
@@ -78,7 +149,84 @@ func WithRecoverFunc(recoverFunc RecoverFuncType) Option {
 // after the cluster upgrading.
 func WithClusterStable(timeWindow time.Duration) Option {
 	return func(d *Dcron) {
-		d.recentJobs = NewRecentJobPacker(timeWindow)
+		d.recentJobsWindow = timeWindow
+	}
+}
+
+// WithMinQuorum makes this node refuse to run jobs when the number of
+// visible nodes drops below `ratio` of the recent maximum or below `minNodes`.
+// It protects the cluster from running jobs twice when a node is
+// partitioned and sees only part of the cluster. The counts seen without
+// quorum are not taken into the recent maximum, so a cluster shrinking
+// below `ratio` at once stays without quorum until the nodes come back,
+// or for 10 quorum windows at most, after which the smaller cluster is
+// taken as the recent maximum, e.g. after a scale-down.
+// It panics if ratio is not in (0, 1] or minNodes is negative.
+func WithMinQuorum(ratio float64, minNodes int) Option {
+	if ratio <= 0 || ratio > 1 {
+		panic("dcron: the quorum ratio must be in (0, 1]")
+	}
+	if minNodes < 0 {
+		panic("dcron: the quorum minNodes must not be negative")
+	}
+	return func(d *Dcron) {
+		d.nodePoolOptions = append(d.nodePoolOptions, func(np *NodePool) {
+			np.minQuorumRatio = ratio
+			np.minQuorumNodes = minNodes
+		})
+	}
+}
+
+// WithQuorumWindow set the duration the recent maximum of visible nodes
+// of WithMinQuorum is taken over, the default is 10 update rounds.
+func WithQuorumWindow(window time.Duration) Option {
+	return func(d *Dcron) {
+		d.nodePoolOptions = append(d.nodePoolOptions, func(np *NodePool) {
+			np.quorumWindow = window
+		})
+	}
+}
+
+// WithStableRounds set how many consecutive update rounds must see the
+// same nodes before the node pool comes to steady, the default is 1.
+func WithStableRounds(n int) Option {
+	return func(d *Dcron) {
+		d.nodePoolOptions = append(d.nodePoolOptions, func(np *NodePool) {
+			np.stableRounds = n
+		})
+	}
+}
+
+// WithMinUpgradeDuration set the minimum duration the node pool holds
+// the Upgrade state after the nodes changed.
+func WithMinUpgradeDuration(dur time.Duration) Option {
+	return func(d *Dcron) {
+		d.nodePoolOptions = append(d.nodePoolOptions, func(np *NodePool) {
+			np.minUpgradeDuration = dur
+		})
+	}
+}
+
+// WithRole set the role of this node, the default role is RoleWorker.
+// A RoleObserver node registers itself and sees the hash ring,
+// but it is never assigned jobs. It needs a driver implementing KVDriver.
+func WithRole(role Role) Option {
+	return func(d *Dcron) {
+		d.nodePoolOptions = append(d.nodePoolOptions, func(np *NodePool) {
+			np.role = role
+		})
+	}
+}
+
+// WithZone set the zone of this node, like an availability zone.
+// The zone is advertised to the other nodes, so the jobs preferring
+// this zone are assigned to the nodes in it. It needs a driver
+// implementing KVDriver.
+func WithZone(zone string) Option {
+	return func(d *Dcron) {
+		d.nodePoolOptions = append(d.nodePoolOptions, func(np *NodePool) {
+			np.zone = zone
+		})
 	}
 }
 
@@ -87,3 +235,56 @@ func RunningLocally() Option {
 		d.runningLocally = true
 	}
 }
+
+// JobOption is Job Option
+type JobOption func(*JobWarpper)
+
+// WithStrictOnce makes the job run exactly once in each tick, even when
+// the cluster is upgrading. Before running, the node claims the tick
+// (jobName, scheduled second) through the driver, and only the node
+// which claims it successfully runs the job. While the cluster is
+// upgrading, the workers in the home zone of the job compete for the
+// claim, the observers never do. The driver must implement ClaimDriver.
+// It can not be used with WithReplicas or WithHotStandby.
+func WithStrictOnce() JobOption {
+	return func(job *JobWarpper) {
+		job.StrictOnce = true
+	}
+}
+
+// WithHomeZone makes the job prefer the nodes in zone. The job is assigned
+// to the nodes in zone, and fails over to the nodes in other zones
+// only when no node in zone is alive.
+func WithHomeZone(zone string) JobOption {
+	return func(job *JobWarpper) {
+		job.HomeZone = zone
+	}
+}
+
+// WithReplicas makes the job run on the first n owners on the hash ring
+// in each tick, for redundancy. With WithHotStandby, the owners other
+// than the primary one are the standbys.
+func WithReplicas(n int) JobOption {
+	return func(job *JobWarpper) {
+		job.Replicas = n
+	}
+}
+
+// WithHotStandby makes the job run on the primary owner only, and on a
+// standby owner if the primary has not reported the completion within
+// grace. The k-th standby waits k times grace. The job has 1 standby
+// unless more replicas are set by WithReplicas. The driver must
+// implement KVDriver.
+func WithHotStandby(grace time.Duration) JobOption {
+	return func(job *JobWarpper) {
+		job.StandbyGrace = grace
+	}
+}
+
+// WithJobCalendar makes the job skip the times excluded by calendar,
+// in addition to the calendars of Dcron. It can be used more than once.
+func WithJobCalendar(calendar cron.Calendar) JobOption {
+	return func(job *JobWarpper) {
+		job.Calendars = append(job.Calendars, calendar)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"container/heap"
 	"sync"
 	"time"
+
+	"github.com/libi/dcron/clock"
 )
 
 type JobWithTime struct {
@@ -46,6 +48,7 @@ type RecentJobPacker struct {
 	sync.Mutex
 	timeWindow time.Duration
 	recentJobs JobWithTimeHeap
+	clock      clock.Clock
 }
 
 func (rjp *RecentJobPacker) AddJob(jobName string, t time.Time) (err error) {
@@ -56,7 +59,7 @@ func (rjp *RecentJobPacker) AddJob(jobName string, t time.Time) (err error) {
 		RunningTime: t,
 	})
 
-	unRencentTime := time.Now().Add(-rjp.timeWindow)
+	unRencentTime := rjp.clock.Now().Add(-rjp.timeWindow)
 	for rjp.recentJobs.Len() > 0 && rjp.recentJobs.Index(0).(JobWithTime).RunningTime.Before(unRencentTime) {
 		heap.Pop(&rjp.recentJobs)
 	}
@@ -76,8 +79,15 @@ func (rjp *RecentJobPacker) PopAllJobs() (jobNames []string) {
 }
 
 func NewRecentJobPacker(timeWindow time.Duration) IRecentJobPacker {
+	return NewRecentJobPackerWithClock(timeWindow, clock.Real())
+}
+
+// NewRecentJobPackerWithClock creates a RecentJobPacker evicting the jobs
+// older than timeWindow on c, the clock of the times passed to AddJob.
+func NewRecentJobPackerWithClock(timeWindow time.Duration, c clock.Clock) IRecentJobPacker {
 	return &RecentJobPacker{
 		timeWindow: timeWindow,
 		recentJobs: make([]JobWithTime, 0),
+		clock:      c,
 	}
 }
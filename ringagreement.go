@@ -0,0 +1,112 @@
+package dcron
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/libi/dcron/consistenthash"
+)
+
+// the probe hashed by the hash function of the ring, so the nodes
+// with different hash functions publish different fingerprints.
+const ringFingerprintProbe = "dcron-ring-fingerprint"
+
+// ringFingerprint is the fingerprint of the hash ring of a node,
+// the nodes with the same fingerprint assign the jobs the same way.
+type ringFingerprint struct {
+	config  string // the hash replicas and hash function
+	members string // the nodes and their zones
+}
+
+func (fp ringFingerprint) encode() string {
+	return fp.config + "-" + fp.members
+}
+
+func decodeRingFingerprint(s string) ringFingerprint {
+	config, members, _ := strings.Cut(s, "-")
+	return ringFingerprint{config: config, members: members}
+}
+
+// ringFingerprint returns the fingerprint of the hash ring,
+// it must be called with the lock held.
+func (np *NodePool) ringFingerprint() ringFingerprint {
+	hashFn := np.hashFn
+	if hashFn == nil {
+		hashFn = consistenthash.CRC32
+	}
+	config := fmt.Sprintf("replicas=%d;probe=%08x", np.hashReplicas, hashFn([]byte(ringFingerprintProbe)))
+	var members strings.Builder
+	for _, node := range np.preNodes {
+		members.WriteString(node)
+		if zone, ok := np.nodeZones[node]; ok {
+			members.WriteString("@" + zone)
+		}
+		members.WriteString("\n")
+	}
+	return ringFingerprint{
+		config:  fmt.Sprintf("%08x", consistenthash.FNV1a([]byte(config))),
+		members: fmt.Sprintf("%08x", consistenthash.FNV1a([]byte(members.String()))),
+	}
+}
+
+func (np *NodePool) ringKey(nodeID string) string {
+	return metaKey(np.serviceName, "ring", nodeID)
+}
+
+// checkRingAgreement publishes the fingerprint of the hash ring of this
+// node, and checks that all nodes in the hash ring publish the same one.
+func (np *NodePool) checkRingAgreement(ctx context.Context) {
+	if !np.ringAgreement {
+		return
+	}
+	kv, ok := np.driver.(KVDriver)
+	if !ok {
+		return
+	}
+	np.rwMut.RLock()
+	version := np.ringVersion
+	self := np.ringFingerprint()
+	peers := make([]string, 0, len(np.preNodes))
+	for _, node := range np.preNodes {
+		if node != np.nodeID {
+			peers = append(peers, node)
+		}
+	}
+	np.rwMut.RUnlock()
+
+	err := kv.SetKV(ctx, np.ringKey(np.nodeID), self.encode(), metaTTLRounds*np.updateDuration)
+	if err != nil {
+		np.logger.Errorf("publish ring fingerprint error %v", err)
+		return
+	}
+	mismatches := make([]string, 0)
+	for _, peer := range peers {
+		value, ok, err := kv.GetKV(ctx, np.ringKey(peer))
+		if err != nil {
+			np.logger.Errorf("get ring fingerprint of node %s error %v", peer, err)
+			return
+		}
+		switch fp := decodeRingFingerprint(value); {
+		case !ok:
+			mismatches = append(mismatches, peer+": no fingerprint")
+		case fp.config != self.config:
+			// it will not recover by itself, the nodes are misconfigured.
+			np.logger.Errorf("node %s has different hash replicas or hash function", peer)
+			mismatches = append(mismatches, peer+": different hash replicas or hash function")
+		case fp.members != self.members:
+			mismatches = append(mismatches, peer+": different nodes")
+		}
+	}
+	sort.Strings(mismatches)
+
+	np.rwMut.Lock()
+	defer np.rwMut.Unlock()
+	if np.ringVersion != version {
+		// the hash ring changed, check again in the next round.
+		return
+	}
+	np.ringAgreed = len(mismatches) == 0
+	np.ringMismatch = strings.Join(mismatches, "; ")
+}